@@ -0,0 +1,423 @@
+package freezelib
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/png"
+	"io"
+	"math"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/etree"
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Animation formats
+const (
+	// AnimatedSVG is an SVG switching between frames with SMIL animations
+	AnimatedSVG = "svg"
+	// AnimatedGIF is an animated GIF image
+	AnimatedGIF = "gif"
+	// AnimatedPNG is an animated PNG (APNG) image
+	AnimatedPNG = "apng"
+)
+
+// finalFrameDelay is how long the last frame of an animation is shown
+const finalFrameDelay = 2 * time.Second
+
+// animationScale is the scale the frames of animated images are rendered at
+const animationScale = 2
+
+// Animation configures the playback of animated output
+type Animation struct {
+	// Speed multiplies the playback speed, 1 when 0
+	Speed float64 `json:"speed"`
+	// IdleTimeLimit is the longest pause in seconds. When 0 the limit of
+	// the recording is used, if any.
+	IdleTimeLimit float64 `json:"idle_time_limit"`
+	// Loops is the number of times the animation plays, forever when 0
+	Loops int `json:"loops"`
+}
+
+// Chunk is ANSI output written to the terminal at a time since the start
+type Chunk struct {
+	Time time.Duration
+	Data string
+}
+
+// frame is a terminal screen of an animation and how long it is shown
+type frame struct {
+	screen *screen
+	delay  time.Duration
+}
+
+// frames replays the events on a terminal and returns the screens shown
+// over time. Events at the same time and events that leave the screen
+// unchanged, including the cursor when it is drawn, are merged into one
+// frame, and the screens are padded to the same size.
+func (a Animation) frames(terminal Terminal, events []CastEvent, idleTimeLimit float64, cursor bool) []frame {
+	if a.IdleTimeLimit > 0 {
+		idleTimeLimit = a.IdleTimeLimit
+	}
+	idle := time.Duration(idleTimeLimit * float64(time.Second))
+	speed := a.Speed
+	if speed == 0 {
+		speed = 1
+	}
+
+	s := newScreen(terminal)
+	frames := []frame{{screen: s.snapshot(terminal.Crop)}}
+	var last, elapsed, start time.Duration
+	for i, event := range events {
+		gap := event.Time - last
+		if gap < 0 {
+			gap = 0
+		}
+		if idle > 0 && gap > idle {
+			gap = idle
+		}
+		elapsed += gap
+		last = event.Time
+
+		switch event.Type {
+		case CastOutput:
+			_, _ = s.Write([]byte(event.Data))
+		case CastResize:
+			if columns, rows, ok := parseCastSize(event.Data); ok {
+				s.resize(columns, rows)
+			}
+		}
+		if i+1 < len(events) && events[i+1].Time == event.Time {
+			continue
+		}
+
+		snapshot := s.snapshot(terminal.Crop)
+		current := &frames[len(frames)-1]
+		switch {
+		case snapshot.String() == current.screen.String() && (!cursor || snapshot.sameCursor(current.screen)):
+		case elapsed == start:
+			current.screen = snapshot
+		default:
+			current.delay = time.Duration(float64(elapsed-start) / speed)
+			frames = append(frames, frame{screen: snapshot})
+			start = elapsed
+		}
+	}
+	frames[len(frames)-1].delay = finalFrameDelay
+
+	// Pad the screens to the largest one
+	var columns, rows int
+	for _, f := range frames {
+		if width := f.screen.width(); width > columns {
+			columns = width
+		}
+		if height := f.screen.height(); height > rows {
+			rows = height
+		}
+	}
+	for _, f := range frames {
+		f.screen.columns = columns
+		f.screen.rows = rows
+	}
+	return frames
+}
+
+// snapshot returns a copy of the screen contents, without the scrollback
+// when crop is set
+func (s *screen) snapshot(crop bool) *screen {
+	c := &screen{
+		lines:   make([][]cell, len(s.lines)),
+		top:     s.top,
+		columns: s.columns,
+		rows:    s.rows,
+		row:     s.row,
+		col:     s.col,
+
+		cursorHidden: s.cursorHidden,
+	}
+	for i, line := range s.lines {
+		c.lines[i] = slices.Clone(line)
+	}
+	if crop {
+		c.crop()
+	}
+	return c
+}
+
+// sameCursor reports whether two screens show the same cursor
+func (s *screen) sameCursor(other *screen) bool {
+	return s.row == other.row && s.col == other.col && s.cursorHidden == other.cursorHidden
+}
+
+// GenerateAnimation generates an animation from chunks of ANSI output
+// written to the configured terminal at the given times. The format is one
+// of AnimatedSVG, AnimatedGIF or AnimatedPNG.
+func (g *Generator) GenerateAnimation(chunks []Chunk, format string) ([]byte, error) {
+	if err := g.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	events := make([]CastEvent, len(chunks))
+	for i, chunk := range chunks {
+		events[i] = CastEvent{Time: chunk.Time, Type: CastOutput, Data: chunk.Data}
+	}
+	return g.generateAnimation(g.config.Animation.frames(g.config.Terminal, events, 0, g.config.Cursor.Shape != ""), format, renderOptions{})
+}
+
+// GenerateAnimationFromCast generates an animation of an asciinema recording
+// (asciicast v2). The recording's terminal size and idle time limit are
+// used, as well as its theme unless a terminal palette is configured.
+func (g *Generator) GenerateAnimationFromCast(r io.Reader, format string) ([]byte, error) {
+	if err := g.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	cast, err := ParseCast(r)
+	if err != nil {
+		return nil, err
+	}
+
+	terminal := Terminal{Columns: cast.Header.Width, Rows: cast.Header.Height, Crop: true}
+	frames := g.config.Animation.frames(terminal, cast.Events, cast.Header.IdleTimeLimit, g.config.Cursor.Shape != "")
+	return g.generateAnimation(frames, format, g.castOptions(cast.Header))
+}
+
+// generateAnimation renders the frames in the given format
+func (g *Generator) generateAnimation(frames []frame, format string, opts renderOptions) ([]byte, error) {
+	switch format {
+	case AnimatedSVG:
+		return g.animateSVG(frames, opts)
+	case AnimatedGIF:
+		images, err := g.renderFrames(frames, opts)
+		if err != nil {
+			return nil, err
+		}
+		return encodeGIF(frames, images, g.config.Animation.Loops)
+	case AnimatedPNG:
+		images, err := g.renderFrames(frames, opts)
+		if err != nil {
+			return nil, err
+		}
+		delays := make([]time.Duration, len(frames))
+		for i, f := range frames {
+			delays[i] = f.delay
+		}
+		return encodeAPNG(images, delays, g.config.Animation.Loops)
+	default:
+		return nil, fmt.Errorf("unknown animation format %q", format)
+	}
+}
+
+// animateSVG renders the frames into one SVG. The window and background
+// are taken from the first frame, and the text group of every frame is
+// shown in turn with a SMIL animation of its visibility. Renderers without
+// SMIL support show the last frame.
+func (g *Generator) animateSVG(frames []frame, opts renderOptions) ([]byte, error) {
+	// Embed fonts once, covering the text of every frame
+	var glyphs strings.Builder
+	for _, f := range frames {
+		glyphs.WriteString(ansi.Strip(f.screen.String()))
+	}
+	opts.glyphs = glyphs.String()
+
+	var total time.Duration
+	for _, f := range frames {
+		total += f.delay
+	}
+
+	var doc *etree.Document
+	var previous *etree.Element
+	var start time.Duration
+	for i, f := range frames {
+		svgData, err := g.generateFromScreen(f.screen, opts)
+		if err != nil {
+			return nil, err
+		}
+		opts.skipFonts = true
+
+		frameDoc := etree.NewDocument()
+		if err := frameDoc.ReadFromBytes(svgData); err != nil {
+			return nil, fmt.Errorf("could not parse SVG: %w", err)
+		}
+		group := frameDoc.Root().SelectElement("g")
+		if group == nil {
+			return nil, errors.New("could not find text element")
+		}
+
+		if doc == nil {
+			doc = frameDoc
+		} else {
+			previous.Parent().InsertChildAt(previous.Index()+1, group)
+		}
+		previous = group
+
+		if len(frames) > 1 {
+			animateFrame(group, start, start+f.delay, total, g.config.Animation.Loops, i == len(frames)-1)
+		}
+		start += f.delay
+	}
+
+	return doc.WriteToBytes()
+}
+
+// animateFrame shows a frame's text group from start to end within an
+// animation of the given duration
+func animateFrame(group *etree.Element, start, end, total time.Duration, loops int, last bool) {
+	if last {
+		group.CreateAttr("visibility", "visible")
+	} else {
+		group.CreateAttr("visibility", "hidden")
+	}
+
+	keyTime := func(t time.Duration) string {
+		return strconv.FormatFloat(float64(t)/float64(total), 'f', 4, 64)
+	}
+	var values, keyTimes []string
+	if start > 0 {
+		values = append(values, "hidden")
+		keyTimes = append(keyTimes, "0")
+	}
+	values = append(values, "visible")
+	keyTimes = append(keyTimes, keyTime(start))
+	if end < total {
+		values = append(values, "hidden")
+		keyTimes = append(keyTimes, keyTime(end))
+	}
+
+	animate := group.CreateElement("animate")
+	animate.CreateAttr("attributeName", "visibility")
+	animate.CreateAttr("calcMode", "discrete")
+	animate.CreateAttr("values", strings.Join(values, ";"))
+	animate.CreateAttr("keyTimes", strings.Join(keyTimes, ";"))
+	animate.CreateAttr("dur", fmt.Sprintf("%.3fs", total.Seconds()))
+	if loops > 0 {
+		animate.CreateAttr("repeatCount", strconv.Itoa(loops))
+		animate.CreateAttr("fill", "freeze")
+	} else {
+		animate.CreateAttr("repeatCount", "indefinite")
+	}
+}
+
+// renderFrames renders the frames to PNG images at the animation scale. The
+// rasterizer has the fonts loaded, so they are not embedded into the frames.
+func (g *Generator) renderFrames(frames []frame, opts renderOptions) ([][]byte, error) {
+	r, err := g.newRasterizer()
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	opts.skipFonts = true
+	images := make([][]byte, len(frames))
+	for i, f := range frames {
+		svgData, err := g.generateFromScreen(f.screen, opts)
+		if err != nil {
+			return nil, err
+		}
+
+		doc := etree.NewDocument()
+		if err := doc.ReadFromBytes(svgData); err != nil {
+			return nil, fmt.Errorf("could not parse SVG: %w", err)
+		}
+		width, _ := strconv.ParseFloat(strings.TrimSuffix(doc.Root().SelectAttrValue("width", "0"), "px"), 64)
+		height, _ := strconv.ParseFloat(strings.TrimSuffix(doc.Root().SelectAttrValue("height", "0"), "px"), 64)
+
+		images[i], err = r.render(svgData, math.Ceil(width*animationScale), math.Ceil(height*animationScale), animationScale)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return images, nil
+}
+
+// encodeGIF encodes the rendered frames as an animated GIF
+func encodeGIF(frames []frame, images [][]byte, loops int) ([]byte, error) {
+	// GIF loop counts are the number of repeats, with 0 looping forever
+	// and -1 playing once
+	anim := &gif.GIF{}
+	switch loops {
+	case 0:
+		anim.LoopCount = 0
+	case 1:
+		anim.LoopCount = -1
+	default:
+		anim.LoopCount = loops - 1
+	}
+
+	for i, data := range images {
+		img, err := png.Decode(bytes.NewReader(data))
+		if err != nil {
+			return nil, fmt.Errorf("could not decode frame: %w", err)
+		}
+		// GIF delays are in hundredths of a second, and shorter delays
+		// than 2 are slowed down by most viewers
+		delay := int(frames[i].delay.Round(10*time.Millisecond) / (10 * time.Millisecond))
+		if delay < 2 {
+			delay = 2
+		}
+		anim.Image = append(anim.Image, quantize(img))
+		anim.Delay = append(anim.Delay, delay)
+	}
+
+	var buf bytes.Buffer
+	if err := gif.EncodeAll(&buf, anim); err != nil {
+		return nil, fmt.Errorf("could not encode GIF: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// quantize converts an image to a paletted image of its 256 most frequent
+// colors. Terminal screens have few colors besides the antialiasing of the
+// text, so this keeps the theme colors exact.
+func quantize(img image.Image) *image.Paletted {
+	bounds := img.Bounds()
+	counts := make(map[color.RGBA]int)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			counts[color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)]++
+		}
+	}
+
+	colors := make([]color.RGBA, 0, len(counts))
+	for c := range counts {
+		colors = append(colors, c)
+	}
+	sort.Slice(colors, func(i, j int) bool {
+		if counts[colors[i]] != counts[colors[j]] {
+			return counts[colors[i]] > counts[colors[j]]
+		}
+		a, b := colors[i], colors[j]
+		return uint32(a.R)<<24|uint32(a.G)<<16|uint32(a.B)<<8|uint32(a.A) <
+			uint32(b.R)<<24|uint32(b.G)<<16|uint32(b.B)<<8|uint32(b.A)
+	})
+	if len(colors) > 256 {
+		colors = colors[:256]
+	}
+
+	palette := make(color.Palette, len(colors))
+	for i, c := range colors {
+		palette[i] = c
+	}
+	paletted := image.NewPaletted(bounds, palette)
+	indices := make(map[color.RGBA]uint8)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.RGBAModel.Convert(img.At(x, y)).(color.RGBA)
+			index, ok := indices[c]
+			if !ok {
+				index = uint8(palette.Index(c))
+				indices[c] = index
+			}
+			paletted.SetColorIndex(x, y, index)
+		}
+	}
+	return paletted
+}
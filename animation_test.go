@@ -0,0 +1,125 @@
+package freezelib
+
+import (
+	"bytes"
+	"image"
+	"image/gif"
+	"image/png"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAnimationFrames(t *testing.T) {
+	cast, err := ParseCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatalf("ParseCast failed: %v", err)
+	}
+
+	tests := []struct {
+		name      string
+		animation Animation
+		delays    []time.Duration
+	}{
+		{"Default", Animation{}, []time.Duration{500 * time.Millisecond, 500 * time.Millisecond, time.Second, finalFrameDelay}},
+		{"Speed", Animation{Speed: 2}, []time.Duration{250 * time.Millisecond, 250 * time.Millisecond, 500 * time.Millisecond, finalFrameDelay}},
+		{"Idle time limit", Animation{IdleTimeLimit: 0.4}, []time.Duration{400 * time.Millisecond, 400 * time.Millisecond, 800 * time.Millisecond, finalFrameDelay}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			terminal := Terminal{Columns: cast.Header.Width, Rows: cast.Header.Height, Crop: true}
+			frames := tt.animation.frames(terminal, cast.Events, 0, false)
+			if len(frames) != len(tt.delays) {
+				t.Fatalf("frames() = %d frames, want %d", len(frames), len(tt.delays))
+			}
+			for i, f := range frames {
+				if f.delay != tt.delays[i] {
+					t.Errorf("frames()[%d].delay = %v, want %v", i, f.delay, tt.delays[i])
+				}
+			}
+			if got := frames[2].screen.String(); got != "$ make\n\x1b[0;32mbuilding\x1b[m\n" {
+				t.Errorf("frames()[2] = %q", got)
+			}
+		})
+	}
+}
+
+func TestGenerateAnimation(t *testing.T) {
+	config := DefaultConfig().SetAnimation(Animation{Loops: 2})
+	chunks := []Chunk{
+		{Time: 0, Data: "one"},
+		{Time: time.Second, Data: "\r\n\x1b[31mtwo"},
+	}
+	svgData, err := NewGenerator(config).GenerateAnimation(chunks, AnimatedSVG)
+	if err != nil {
+		t.Fatalf("GenerateAnimation failed: %v", err)
+	}
+
+	for _, expected := range []string{
+		`clip-path="url(#terminalMask)" visibility="hidden">`,
+		`<animate attributeName="visibility" calcMode="discrete" values="visible;hidden" keyTimes="0.0000;0.3333" dur="3.000s" repeatCount="2" fill="freeze"/>`,
+		`values="hidden;visible" keyTimes="0;0.3333"`,
+		`fill="#FF0000">two`,
+	} {
+		if !strings.Contains(string(svgData), expected) {
+			t.Errorf("SVG should contain %s", expected)
+		}
+	}
+
+	for _, tt := range []struct {
+		loops     int
+		loopCount int
+	}{{0, 0}, {1, -1}, {3, 2}} {
+		gifData, err := NewGenerator(DefaultConfig().SetAnimation(Animation{Loops: tt.loops})).GenerateAnimation(chunks, AnimatedGIF)
+		if err != nil {
+			t.Fatalf("GenerateAnimation failed: %v", err)
+		}
+		anim, err := gif.DecodeAll(bytes.NewReader(gifData))
+		if err != nil {
+			t.Fatalf("gif.DecodeAll failed: %v", err)
+		}
+		if anim.LoopCount != tt.loopCount {
+			t.Errorf("GenerateAnimation() with %d loops has LoopCount %d, want %d", tt.loops, anim.LoopCount, tt.loopCount)
+		}
+	}
+
+	if _, err := NewGenerator(config).GenerateAnimation(chunks, "mp4"); err == nil {
+		t.Error("GenerateAnimation() should fail for unknown formats")
+	}
+}
+
+func TestEncodeAPNG(t *testing.T) {
+	var images [][]byte
+	for i := 0; i < 3; i++ {
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, image.NewGray(image.Rect(0, 0, 4, 2))); err != nil {
+			t.Fatalf("png.Encode failed: %v", err)
+		}
+		images = append(images, buf.Bytes())
+	}
+
+	data, err := encodeAPNG(images, []time.Duration{time.Second, time.Second, time.Second}, 1)
+	if err != nil {
+		t.Fatalf("encodeAPNG failed: %v", err)
+	}
+	chunks, err := readPNGChunks(data)
+	if err != nil {
+		t.Fatalf("readPNGChunks failed: %v", err)
+	}
+	var kinds []string
+	for _, chunk := range chunks {
+		kinds = append(kinds, chunk.kind)
+	}
+	if got, want := strings.Join(kinds, " "), "IHDR acTL fcTL IDAT fcTL fdAT fcTL fdAT IEND"; got != want {
+		t.Errorf("encodeAPNG() chunks = %s, want %s", got, want)
+	}
+
+	// Viewers without APNG support show the first image
+	if _, err := png.Decode(bytes.NewReader(data)); err != nil {
+		t.Errorf("png.Decode() failed: %v", err)
+	}
+
+	if _, err := encodeAPNG([][]byte{images[0], []byte("GIF89a")}, make([]time.Duration, 2), 0); err == nil {
+		t.Error("encodeAPNG() should fail for images that are not PNG")
+	}
+}
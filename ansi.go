@@ -6,219 +6,231 @@ import (
 
 	"github.com/beevik/etree"
 	"github.com/charmbracelet/x/ansi"
-	"github.com/mattn/go-runewidth"
+	"github.com/charmbracelet/x/cellbuf"
+	"github.com/landaiqing/freezelib/svg"
 )
 
-// dispatcher handles ANSI escape sequences and converts them to SVG
-type dispatcher struct {
-	lines   []*etree.Element
-	svg     *etree.Element
-	config  *Config
-	scale   float64
-	row     int
-	col     int
-	bg      *etree.Element
-	bgWidth int
+// ansiRenderer draws the cells of a terminal screen as SVG
+type ansiRenderer struct {
+	// group receives backgrounds and decoration lines
+	group   *etree.Element
+	palette TerminalPalette
+	links   Hyperlinks
+	layout  cellLayout
 }
 
-// newDispatcher creates a new ANSI dispatcher
-func newDispatcher(lines []*etree.Element, svg *etree.Element, config *Config, scale float64) *dispatcher {
-	return &dispatcher{
-		lines:  lines,
-		svg:    svg,
-		config: config,
-		scale:  scale,
-		row:    0,
-		col:    0,
-	}
+// cellRun is a sequence of cells on a row sharing a style and hyperlink
+type cellRun struct {
+	row, col int
+	// width is the number of columns covered, including the second half of
+	// wide runes
+	width int
+	text  string
+	style cellStyle
+	link  string
 }
 
-// Print handles printable characters
-func (p *dispatcher) Print(r rune) {
-	p.row = clamp(p.row, 0, len(p.lines)-1)
-	// insert the rune in the last tspan
-	children := p.lines[p.row].ChildElements()
-	var lastChild *etree.Element
-	isFirstChild := len(children) == 0
-	if isFirstChild {
-		lastChild = etree.NewElement("tspan")
-		lastChild.CreateAttr("xml:space", "preserve")
-		p.lines[p.row].AddChild(lastChild)
-	} else {
-		lastChild = children[len(children)-1]
-	}
-
-	if runewidth.RuneWidth(r) > 1 {
-		newChild := lastChild.Copy()
-		newChild.SetText(string(r))
-		newChild.CreateAttr("dx", fmt.Sprintf("%.2fpx", (p.config.Font.Size/5)*p.scale))
-		p.lines[p.row].AddChild(newChild)
-	} else {
-		lastChild.SetText(lastChild.Text() + string(r))
+// renderANSI draws the screen rows into the text lines. Every run of cells
+// is positioned at its column, so text, backgrounds and wide runes follow
+// the terminal grid whatever the advances of the fonts used. The palette's
+// default colors must be set, as inverse video uses them.
+func renderANSI(s *screen, lines []*etree.Element, group *etree.Element, palette TerminalPalette, links Hyperlinks, layout cellLayout) {
+	r := &ansiRenderer{
+		group:   group,
+		palette: palette,
+		links:   links,
+		layout:  layout,
 	}
+	for row, line := range lines {
+		if row >= len(s.lines) {
+			break
+		}
+		var anchor *etree.Element
+		// Backgrounds and decorations span neighbouring runs of the same
+		// style, so they are drawn without seams
+		var decorated *cellRun
+		for _, run := range r.runs(row, s.lines[row]) {
+			parent := line
+			switch {
+			case run.link == "":
+				anchor = nil
+			case anchor == nil || anchor.SelectAttrValue("href", "") != run.link:
+				anchor = etree.NewElement("a")
+				anchor.CreateAttr("href", run.link)
+				line.AddChild(anchor)
+			}
+			if anchor != nil {
+				parent = anchor
+			}
+			if span := r.span(run); span != nil {
+				parent.AddChild(span)
+			}
 
-	p.col += runewidth.RuneWidth(r)
-	if p.bg != nil {
-		p.bgWidth += runewidth.RuneWidth(r)
+			if decorated != nil && decorated.style.equal(run.style) && decorated.link == run.link {
+				decorated.width += run.width
+				continue
+			}
+			if decorated != nil {
+				r.decorate(*decorated)
+			}
+			decorated = &run
+		}
+		if decorated != nil {
+			r.decorate(*decorated)
+		}
 	}
 }
 
-// Execute handles control characters
-func (p *dispatcher) Execute(code byte) {
-	if code == '\t' {
-		for p.col%16 != 0 {
-			p.Print(' ')
+// runs splits a row into runs of cells. Wide runes usually come from
+// fallback fonts whose advances do not match the grid, so they get runs of
+// their own. Stripped links and links with an unsafe URL scheme are
+// rendered as plain text.
+func (r *ansiRenderer) runs(row int, cells []cell) []cellRun {
+	var runs []cellRun
+	var text strings.Builder
+	wide := false
+	for col, c := range cells {
+		link := c.Link.URL
+		if r.links.Strip || !isSafeLink(link) {
+			link = ""
 		}
+		if c.continuation() {
+			if len(runs) > 0 {
+				runs[len(runs)-1].width++
+			}
+			continue
+		}
+		startsWide := col+1 < len(cells) && cells[col+1].continuation()
+		if n := len(runs); n == 0 || wide || startsWide || !c.style().equal(runs[n-1].style) || link != runs[n-1].link {
+			if n > 0 {
+				runs[n-1].text = text.String()
+				text.Reset()
+			}
+			runs = append(runs, cellRun{row: row, col: col, style: c.style(), link: link})
+		}
+		runs[len(runs)-1].width++
+		text.WriteString(c.String())
+		wide = startsWide
 	}
-	if code == '\n' {
-		p.endBackground()
-		p.row++
-		p.col = 0
-	}
-}
-
-// endBackground ends the current background span
-func (p *dispatcher) endBackground() {
-	if p.bg == nil {
-		return
+	if len(runs) > 0 {
+		runs[len(runs)-1].text = text.String()
 	}
-	p.bg.CreateAttr("width", fmt.Sprintf("%.2fpx", float64(p.bgWidth)*(p.config.Font.Size/fontHeightToWidthRatio)*p.scale))
-	p.bg = nil
-	p.bgWidth = 0
+	return runs
 }
 
-// CsiDispatch handles CSI (Control Sequence Introducer) sequences
-func (p *dispatcher) CsiDispatch(cmd ansi.Cmd, params ansi.Params) {
-	if cmd != 'm' {
-		// ignore incomplete or non Style (SGR) sequences
-		return
+// span creates the text of a run, or nil when the run has no visible
+// text. Backgrounds and decorations are drawn separately, as SVG renderers
+// differ in how they combine text decorations.
+func (r *ansiRenderer) span(run cellRun) *etree.Element {
+	if strings.TrimSpace(run.text) == "" {
+		return nil
 	}
 
 	span := etree.NewElement("tspan")
 	span.CreateAttr("xml:space", "preserve")
-	reset := func() {
-		// reset ANSI, this is done by creating a new empty tspan,
-		// which would reset all the styles such that when text is appended to the last
-		// child of this line there is no styling applied.
-		if p.row < len(p.lines) {
-			p.lines[p.row].AddChild(span)
-		}
-		p.endBackground()
+	span.CreateAttr("x", fmt.Sprintf("%.2fpx", r.layout.cellX(run.col)))
+	if fg, _ := r.colors(run); fg != "" {
+		span.CreateAttr("fill", fg)
+	}
+	if run.style.has(cellbuf.BoldAttr) {
+		span.CreateAttr("font-weight", "bold")
+	}
+	if run.style.has(cellbuf.ItalicAttr) {
+		span.CreateAttr("font-style", "italic")
 	}
+	switch {
+	case run.style.has(cellbuf.ConcealAttr):
+		span.CreateAttr("fill-opacity", "0")
+	case run.style.has(cellbuf.FaintAttr):
+		span.CreateAttr("fill-opacity", "0.5")
+	}
+	// Blinking text is rendered steady
+	span.SetText(run.text)
+	return span
+}
 
-	if len(params) == 0 {
-		// zero params means reset
-		reset()
-		return
+// decorate draws the background and decoration lines of a run
+func (r *ansiRenderer) decorate(run cellRun) {
+	x := r.layout.cellX(run.col)
+	y := r.layout.cellY(run.row)
+	width := float64(run.width) * r.layout.cellWidth
+
+	fg, bg := r.colors(run)
+	if bg != "" {
+		r.group.InsertChildAt(0, svg.CreateRect(x, y, width, r.layout.lineHeight, bg))
 	}
 
-	var i int
-	for i < len(params) {
-		v := params[i].Param(0)
-		switch v {
-		case 0:
-			reset()
-		case 1:
-			// Bold - not implemented in SVG for now
-			p.lines[p.row].AddChild(span)
-		case 9:
-			span.CreateAttr("text-decoration", "line-through")
-			p.lines[p.row].AddChild(span)
-		case 3:
-			span.CreateAttr("font-style", "italic")
-			p.lines[p.row].AddChild(span)
-		case 4:
-			span.CreateAttr("text-decoration", "underline")
-			p.lines[p.row].AddChild(span)
-		case 30, 31, 32, 33, 34, 35, 36, 37, 90, 91, 92, 93, 94, 95, 96, 97:
-			span.CreateAttr("fill", ansiPalette[v])
-			p.lines[p.row].AddChild(span)
-		case 38:
-			i++
-			if i < len(params) {
-				switch params[i].Param(0) {
-				case 5:
-					if i+1 < len(params) {
-						n := params[i+1].Param(0)
-						i++
-						fill := palette[n]
-						span.CreateAttr("fill", fill)
-						p.lines[p.row].AddChild(span)
-					}
-				case 2:
-					if i+3 < len(params) {
-						r := params[i+1].Param(0)
-						g := params[i+2].Param(0)
-						b := params[i+3].Param(0)
-						i += 3
-						fill := fmt.Sprintf("rgb(%d,%d,%d)", r, g, b)
-						span.CreateAttr("fill", fill)
-						p.lines[p.row].AddChild(span)
-					}
-				}
-			}
-		case 40, 41, 42, 43, 44, 45, 46, 47, 100, 101, 102, 103, 104, 105, 106, 107:
-			// Background colors
-			p.endBackground()
-			p.bg = etree.NewElement("rect")
-			p.bg.CreateAttr("fill", ansiPalette[v-10])
-			p.bg.CreateAttr("height", fmt.Sprintf("%.2fpx", p.config.Font.Size*p.config.LineHeight))
-			p.bg.CreateAttr("x", fmt.Sprintf("%.2fpx", float64(p.col)*(p.config.Font.Size/fontHeightToWidthRatio)*p.scale))
-			p.bg.CreateAttr("y", fmt.Sprintf("%.2fpx", float64(p.row)*p.config.Font.Size*p.config.LineHeight))
-			p.svg.InsertChildAt(0, p.bg)
-		case 48:
-			i++
-			if i < len(params) {
-				switch params[i].Param(0) {
-				case 5:
-					if i+1 < len(params) {
-						n := params[i+1].Param(0)
-						i++
-						p.endBackground()
-						p.bg = etree.NewElement("rect")
-						p.bg.CreateAttr("fill", palette[n])
-						p.bg.CreateAttr("height", fmt.Sprintf("%.2fpx", p.config.Font.Size*p.config.LineHeight))
-						p.bg.CreateAttr("x", fmt.Sprintf("%.2fpx", float64(p.col)*(p.config.Font.Size/fontHeightToWidthRatio)*p.scale))
-						p.bg.CreateAttr("y", fmt.Sprintf("%.2fpx", float64(p.row)*p.config.Font.Size*p.config.LineHeight))
-						p.svg.InsertChildAt(0, p.bg)
-					}
-				case 2:
-					if i+3 < len(params) {
-						r := params[i+1].Param(0)
-						g := params[i+2].Param(0)
-						b := params[i+3].Param(0)
-						i += 3
-						p.endBackground()
-						p.bg = etree.NewElement("rect")
-						p.bg.CreateAttr("fill", fmt.Sprintf("rgb(%d,%d,%d)", r, g, b))
-						p.bg.CreateAttr("height", fmt.Sprintf("%.2fpx", p.config.Font.Size*p.config.LineHeight))
-						p.bg.CreateAttr("x", fmt.Sprintf("%.2fpx", float64(p.col)*(p.config.Font.Size/fontHeightToWidthRatio)*p.scale))
-						p.bg.CreateAttr("y", fmt.Sprintf("%.2fpx", float64(p.row)*p.config.Font.Size*p.config.LineHeight))
-						p.svg.InsertChildAt(0, p.bg)
-					}
-				}
-			}
+	if run.style.has(cellbuf.ConcealAttr) {
+		return
+	}
+	if fg == "" {
+		fg = r.palette.Foreground
+	}
+	var lines []*etree.Element
+	underline := run.style.UlStyle
+	if underline == cellbuf.NoUnderline && run.link != "" && r.links.Underline {
+		underline = cellbuf.SingleUnderline
+	}
+	if underline != cellbuf.NoUnderline {
+		color := paletteColor(run.style.Ul, r.palette)
+		if color == "" {
+			color = fg
+		}
+		lines = append(lines, r.underline(x, y+r.layout.underline, width, color, underline)...)
+	}
+	if run.style.has(cellbuf.StrikethroughAttr) {
+		lines = append(lines, r.line(x, y+r.layout.strikethrough, width, fg))
+	}
+	if run.style.overline {
+		lines = append(lines, r.line(x, y+r.layout.overline, width, fg))
+	}
+	for _, line := range lines {
+		if run.style.has(cellbuf.FaintAttr) {
+			line.CreateAttr("opacity", "0.5")
 		}
-		i++
+		r.group.AddChild(line)
 	}
 }
 
-// processANSI processes ANSI escape sequences in the input text
-func processANSI(input string, lines []*etree.Element, svg *etree.Element, config *Config, scale float64) {
-	d := newDispatcher(lines, svg, config, scale)
-	parser := ansi.NewParser()
-	parser.SetHandler(ansi.Handler{
-		Print:     d.Print,
-		HandleCsi: d.CsiDispatch,
-		Execute:   d.Execute,
-	})
+// line creates a solid decoration line centered on y
+func (r *ansiRenderer) line(x, y, width float64, color string) *etree.Element {
+	thickness := r.layout.lineThickness
+	return svg.CreateRect(x, y-thickness/2, width, thickness, color)
+}
 
-	for _, line := range strings.Split(input, "\n") {
-		parser.Parse([]byte(line))
-		d.Execute(ansi.LF) // simulate a newline
+// underline creates the decoration lines of an underline style, which CSS
+// text-decoration cannot express in SVG
+func (r *ansiRenderer) underline(x, y, width float64, color string, style cellbuf.UnderlineStyle) []*etree.Element {
+	thickness := r.layout.lineThickness
+	switch style {
+	case cellbuf.DoubleUnderline:
+		return []*etree.Element{
+			r.line(x, y-thickness, width, color),
+			r.line(x, y+thickness, width, color),
+		}
+	case cellbuf.CurlyUnderline:
+		return []*etree.Element{svg.CreateWave(x, y, width, thickness*1.5, r.layout.cellWidth, thickness, color)}
+	case cellbuf.DottedUnderline:
+		dots := fmt.Sprintf("%.2f", thickness*2)
+		return []*etree.Element{svg.CreateLine(x, y, width, thickness*2, color, dots)}
+	case cellbuf.DashedUnderline:
+		dashes := fmt.Sprintf("%.2f %.2f", r.layout.cellWidth/2, r.layout.cellWidth/4)
+		return []*etree.Element{svg.CreateLine(x, y, width, thickness, color, dashes)}
+	default:
+		return []*etree.Element{r.line(x, y, width, color)}
 	}
 }
 
+// colors returns the foreground and background colors of a run, with the
+// link color applied to hyperlinks
+func (r *ansiRenderer) colors(run cellRun) (string, string) {
+	fg, bg := run.style.colors(r.palette)
+	if run.link != "" && r.links.Color != "" && !run.style.has(cellbuf.ReverseAttr) {
+		fg = parseColor(r.links.Color)
+	}
+	return fg, bg
+}
+
 // stripANSI removes ANSI escape sequences from text
 func stripANSI(input string) string {
 	return ansi.Strip(input)
@@ -240,28 +252,6 @@ func clamp(value, min, max int) int {
 	return value
 }
 
-const fontHeightToWidthRatio = 1.68
-
-// ANSI color palette
-var ansiPalette = map[int]string{
-	30: "#000000", // black
-	31: "#FF0000", // red
-	32: "#00FF00", // green
-	33: "#FFFF00", // yellow
-	34: "#0000FF", // blue
-	35: "#FF00FF", // magenta
-	36: "#00FFFF", // cyan
-	37: "#FFFFFF", // white
-	90: "#808080", // bright black (gray)
-	91: "#FF8080", // bright red
-	92: "#80FF80", // bright green
-	93: "#FFFF80", // bright yellow
-	94: "#8080FF", // bright blue
-	95: "#FF80FF", // bright magenta
-	96: "#80FFFF", // bright cyan
-	97: "#FFFFFF", // bright white
-}
-
 // 256-color palette
 var palette = []string{
 	"#000000", "#800000", "#008000", "#808000", "#000080", "#800080", "#008080", "#c0c0c0",
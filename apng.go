@@ -0,0 +1,126 @@
+package freezelib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"math"
+	"time"
+)
+
+// pngSignature starts every PNG file
+const pngSignature = "\x89PNG\r\n\x1a\n"
+
+// pngChunk is a chunk of a PNG file
+type pngChunk struct {
+	kind string
+	data []byte
+}
+
+// readPNGChunks splits PNG data into its chunks
+func readPNGChunks(data []byte) ([]pngChunk, error) {
+	if !bytes.HasPrefix(data, []byte(pngSignature)) {
+		return nil, errors.New("not a PNG image")
+	}
+	data = data[len(pngSignature):]
+
+	var chunks []pngChunk
+	for len(data) > 0 {
+		if len(data) < 12 {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		length := binary.BigEndian.Uint32(data)
+		if uint64(len(data)) < 12+uint64(length) {
+			return nil, errors.New("truncated PNG chunk")
+		}
+		chunks = append(chunks, pngChunk{kind: string(data[4:8]), data: data[8 : 8+length]})
+		data = data[12+length:]
+	}
+	return chunks, nil
+}
+
+// writePNGChunk writes a chunk with its length and checksum
+func writePNGChunk(buf *bytes.Buffer, kind string, data []byte) {
+	header := binary.BigEndian.AppendUint32(nil, uint32(len(data)))
+	header = append(header, kind...)
+	crc := crc32.NewIEEE()
+	crc.Write(header[4:])
+	crc.Write(data)
+
+	buf.Write(header)
+	buf.Write(data)
+	buf.Write(binary.BigEndian.AppendUint32(nil, crc.Sum32()))
+}
+
+// encodeAPNG combines PNG images of the same size into an animated PNG,
+// showing each for its delay and playing loops times, forever when 0. The
+// first image is also the default image shown by viewers without APNG
+// support.
+func encodeAPNG(images [][]byte, delays []time.Duration, loops int) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteString(pngSignature)
+
+	var sequence, width, height uint32
+	for i, image := range images {
+		chunks, err := readPNGChunks(image)
+		if err != nil {
+			return nil, fmt.Errorf("could not read frame %d: %w", i, err)
+		}
+		if len(chunks) == 0 || chunks[0].kind != "IHDR" || len(chunks[0].data) < 8 {
+			return nil, fmt.Errorf("could not read frame %d: missing header", i)
+		}
+		w := binary.BigEndian.Uint32(chunks[0].data)
+		h := binary.BigEndian.Uint32(chunks[0].data[4:])
+		if i == 0 {
+			width, height = w, h
+		} else if w != width || h != height {
+			return nil, fmt.Errorf("frame %d is %dx%d, want %dx%d", i, w, h, width, height)
+		}
+
+		control := false
+		for _, chunk := range chunks {
+			switch {
+			case chunk.kind == "IDAT":
+				if !control {
+					writePNGChunk(&buf, "fcTL", frameControl(sequence, width, height, delays[i]))
+					sequence++
+					control = true
+				}
+				if i == 0 {
+					writePNGChunk(&buf, "IDAT", chunk.data)
+				} else {
+					writePNGChunk(&buf, "fdAT", append(binary.BigEndian.AppendUint32(nil, sequence), chunk.data...))
+					sequence++
+				}
+			case chunk.kind == "IEND":
+			case i == 0:
+				// Keep the header and ancillary chunks of the first image
+				writePNGChunk(&buf, chunk.kind, chunk.data)
+				if chunk.kind == "IHDR" {
+					animationControl := binary.BigEndian.AppendUint32(nil, uint32(len(images)))
+					animationControl = binary.BigEndian.AppendUint32(animationControl, uint32(loops))
+					writePNGChunk(&buf, "acTL", animationControl)
+				}
+			}
+		}
+	}
+	writePNGChunk(&buf, "IEND", nil)
+	return buf.Bytes(), nil
+}
+
+// frameControl returns the fcTL chunk of a full frame shown for the delay
+func frameControl(sequence, width, height uint32, delay time.Duration) []byte {
+	data := binary.BigEndian.AppendUint32(nil, sequence)
+	data = binary.BigEndian.AppendUint32(data, width)
+	data = binary.BigEndian.AppendUint32(data, height)
+	// The frame is placed at the origin
+	data = binary.BigEndian.AppendUint32(data, 0)
+	data = binary.BigEndian.AppendUint32(data, 0)
+	// The delay is in milliseconds
+	data = binary.BigEndian.AppendUint16(data, uint16(clamp(int(delay.Milliseconds()), 0, math.MaxUint16)))
+	data = binary.BigEndian.AppendUint16(data, 1000)
+	// The frame is not disposed and replaces the previous one
+	return append(data, 0, 0)
+}
@@ -0,0 +1,191 @@
+package freezelib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// CaptureOptions configures how the output of a command is captured
+type CaptureOptions struct {
+	// Columns and Rows are the size of the pseudo-terminal, 80x24 when 0
+	Columns int
+	Rows    int
+	// Timeout stops the command after the given time, never when 0
+	Timeout time.Duration
+	// MaxOutput is the number of bytes of output kept, 1 MiB when 0. The
+	// command is stopped once it writes more.
+	MaxOutput int
+	// Prompt is shown before the command by CaptureCommand, "$ " when
+	// empty. GenerateFromCommand uses the configured prompt instead.
+	Prompt string
+}
+
+// withDefaults returns the options with defaults for unset values
+func (o CaptureOptions) withDefaults() CaptureOptions {
+	if o.Columns <= 0 {
+		o.Columns = 80
+	}
+	if o.Rows <= 0 {
+		o.Rows = 24
+	}
+	if o.MaxOutput <= 0 {
+		o.MaxOutput = 1024 * 1024
+	}
+	if o.Prompt == "" {
+		o.Prompt = "$ "
+	}
+	return o
+}
+
+// CaptureCommand runs a command in a pseudo-terminal, so that it keeps its
+// colors, and returns a prompt line showing the command followed by the
+// command's output. The output captured so far is also returned when the
+// command fails, times out or writes too much. Pseudo-terminals are only
+// supported on Linux; elsewhere an error is returned.
+func CaptureCommand(ctx context.Context, cmd *exec.Cmd, opts CaptureOptions) (string, error) {
+	opts = opts.withDefaults()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	master, slave, err := openPTY(opts.Columns, opts.Rows)
+	if err != nil {
+		return "", err
+	}
+	defer master.Close()
+
+	cmd.Stdin = slave
+	cmd.Stdout = slave
+	cmd.Stderr = slave
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	if !slices.ContainsFunc(cmd.Env, func(v string) bool { return strings.HasPrefix(v, "TERM=") }) {
+		cmd.Env = append(cmd.Env, "TERM=xterm-256color")
+	}
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	setControllingTerminal(cmd.SysProcAttr)
+
+	err = cmd.Start()
+	slave.Close()
+	if err != nil {
+		return "", fmt.Errorf("failed to start command: %w", err)
+	}
+
+	// Read until the command and every process sharing its terminal have
+	// exited, keeping at most MaxOutput bytes
+	output := make(chan []byte, 1)
+	truncated := false
+	go func() {
+		var buf []byte
+		chunk := make([]byte, 32*1024)
+		for {
+			n, err := master.Read(chunk)
+			buf = append(buf, chunk[:n]...)
+			if len(buf) > opts.MaxOutput {
+				buf = buf[:opts.MaxOutput]
+				truncated = true
+				_ = killProcessGroup(cmd.Process)
+				break
+			}
+			if err != nil {
+				break
+			}
+		}
+		output <- buf
+	}()
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		_ = killProcessGroup(cmd.Process)
+		<-done
+		err = fmt.Errorf("command stopped: %w", ctx.Err())
+	}
+
+	// Background processes may keep the terminal open, so stop reading
+	// shortly after the command exits
+	_ = master.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	captured := <-output
+	if truncated {
+		err = fmt.Errorf("command output exceeds %d bytes", opts.MaxOutput)
+	}
+
+	return opts.Prompt + commandLine(cmd.Args) + "\r\n" + string(captured), err
+}
+
+// commandLine returns the arguments of a command as typed in a shell,
+// quoting arguments with special characters
+func commandLine(args []string) string {
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.ContainsAny(arg, " \t\n\"'\\$`|&;<>()*?[]{}~#!") {
+			arg = "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+		}
+		quoted[i] = arg
+	}
+	return strings.Join(quoted, " ")
+}
+
+// GenerateFromCommand runs a command in a pseudo-terminal and generates an
+// SVG of its output below a line showing the command after the configured
+// prompt. The terminal size defaults to the configured one. A command
+// exiting with an error is still rendered. When the command times out or
+// writes more than MaxOutput, the output captured so far is rendered and
+// returned together with the error. Like CaptureCommand, it is only
+// supported on Linux.
+func (g *Generator) GenerateFromCommand(ctx context.Context, cmd *exec.Cmd, opts CaptureOptions) ([]byte, error) {
+	if err := g.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	if opts.Columns == 0 {
+		opts.Columns = g.config.Terminal.Columns
+	}
+	if opts.Rows == 0 {
+		opts.Rows = g.config.Terminal.Rows
+	}
+	opts = opts.withDefaults()
+
+	output, err := CaptureCommand(ctx, cmd, opts)
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		err = nil
+	} else if err != nil && output == "" {
+		// the command could not be started
+		return nil, err
+	}
+
+	// Draw the command line with the configured prompt, after the lines
+	// of the prompt's commands, and leave out the line break the next
+	// prompt would follow
+	_, output, _ = strings.Cut(output, "\r\n")
+	commands := append(append([]string(nil), g.config.Prompt.Commands...), commandLine(cmd.Args))
+	output = g.config.Prompt.prepend(commands, strings.TrimRight(output, "\r\n"), opts.Columns)
+
+	// Wrap at the width of the pseudo-terminal
+	terminal := g.config.Terminal
+	terminal.Columns = opts.Columns
+	svgData, renderErr := g.generateFromScreen(emulate(output, terminal), renderOptions{})
+	if renderErr != nil {
+		return nil, renderErr
+	}
+	if err != nil {
+		return svgData, fmt.Errorf("rendered partial output: %w", err)
+	}
+	return svgData, nil
+}
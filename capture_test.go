@@ -0,0 +1,74 @@
+package freezelib
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("capturing commands is only supported on Linux")
+	}
+
+	tests := []struct {
+		name     string
+		args     []string
+		opts     CaptureOptions
+		expected string
+		fails    bool
+	}{
+		{"Colors", []string{"sh", "-c", `printf '\033[31mred\033[0m\n'`}, CaptureOptions{}, "$ sh -c 'printf '\\''\\033[31mred\\033[0m\\n'\\'''\r\n\x1b[31mred\x1b[0m\r\n", false},
+		{"Size", []string{"stty", "size"}, CaptureOptions{Columns: 40, Rows: 10, Prompt: "> "}, "> stty size\r\n10 40\r\n", false},
+		{"Exit status", []string{"sh", "-c", "echo failed; exit 3"}, CaptureOptions{}, "$ sh -c 'echo failed; exit 3'\r\nfailed\r\n", true},
+		{"Output limit", []string{"sh", "-c", "yes"}, CaptureOptions{MaxOutput: 10}, "$ sh -c yes\r\ny\r\ny\r\ny\r\ny", true},
+		{"Timeout", []string{"sh", "-c", "echo start; sleep 10"}, CaptureOptions{Timeout: 200 * time.Millisecond}, "$ sh -c 'echo start; sleep 10'\r\nstart\r\n", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			output, err := CaptureCommand(context.Background(), exec.Command(tt.args[0], tt.args[1:]...), tt.opts)
+			if (err != nil) != tt.fails {
+				t.Errorf("CaptureCommand() error = %v, want failure %v", err, tt.fails)
+			}
+			if output != tt.expected {
+				t.Errorf("CaptureCommand() = %q, want %q", output, tt.expected)
+			}
+		})
+	}
+}
+
+func TestGenerateFromCommand(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("capturing commands is only supported on Linux")
+	}
+
+	cmd := exec.Command("sh", "-c", `printf '\033[32mok\033[0m\n'; exit 1`)
+	svgData, err := NewGenerator(DefaultConfig()).GenerateFromCommand(context.Background(), cmd, CaptureOptions{Columns: 20})
+	if err != nil {
+		t.Fatalf("GenerateFromCommand failed: %v", err)
+	}
+	for _, expected := range []string{`>$ sh -c `, `fill="#00FF00">ok`} {
+		if !strings.Contains(string(svgData), expected) {
+			t.Errorf("SVG should contain %s", expected)
+		}
+	}
+}
+
+func TestGenerateFromCommandTimeout(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("capturing commands is only supported on Linux")
+	}
+
+	cmd := exec.Command("sh", "-c", "echo started; sleep 10")
+	svgData, err := NewGenerator(DefaultConfig()).GenerateFromCommand(context.Background(), cmd, CaptureOptions{Timeout: 500 * time.Millisecond})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("GenerateFromCommand() error = %v, want a timeout", err)
+	}
+	if !strings.Contains(string(svgData), ">started</tspan>") {
+		t.Errorf("SVG should contain the output captured before the timeout")
+	}
+}
@@ -0,0 +1,185 @@
+package freezelib
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cast is an asciinema terminal recording in the asciicast v2 format
+type Cast struct {
+	Header CastHeader
+	Events []CastEvent
+}
+
+// CastHeader is the first line of an asciicast v2 recording
+type CastHeader struct {
+	Version int `json:"version"`
+	// Width and Height are the terminal columns and rows
+	Width     int   `json:"width"`
+	Height    int   `json:"height"`
+	Timestamp int64 `json:"timestamp"`
+	// IdleTimeLimit is the longest pause in seconds that should be replayed
+	IdleTimeLimit float64    `json:"idle_time_limit"`
+	Title         string     `json:"title"`
+	Theme         *CastTheme `json:"theme"`
+}
+
+// CastTheme is the terminal theme of a recording
+type CastTheme struct {
+	Foreground string `json:"fg"`
+	Background string `json:"bg"`
+	// Palette holds 8 or 16 colors separated by colons
+	Palette string `json:"palette"`
+}
+
+// Cast event types
+const (
+	CastOutput = "o"
+	CastInput  = "i"
+	CastMarker = "m"
+	CastResize = "r"
+)
+
+// CastEvent is an event of a recording
+type CastEvent struct {
+	// Time is the time since the start of the recording
+	Time time.Duration
+	Type string
+	Data string
+}
+
+// ParseCast parses an asciicast v2 recording
+func ParseCast(r io.Reader) (*Cast, error) {
+	scanner := bufio.NewScanner(r)
+	// Output events can be long
+	scanner.Buffer(nil, 16*1024*1024)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read cast: %w", err)
+		}
+		return nil, errors.New("failed to parse cast: missing header")
+	}
+	var cast Cast
+	if err := json.Unmarshal(scanner.Bytes(), &cast.Header); err != nil {
+		return nil, fmt.Errorf("failed to parse cast header: %w", err)
+	}
+	if cast.Header.Version != 2 {
+		return nil, fmt.Errorf("unsupported asciicast version %d", cast.Header.Version)
+	}
+
+	for line := 2; scanner.Scan(); line++ {
+		data := bytes.TrimSpace(scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+		event, err := parseCastEvent(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse cast event on line %d: %w", line, err)
+		}
+		cast.Events = append(cast.Events, event)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read cast: %w", err)
+	}
+	return &cast, nil
+}
+
+// parseCastEvent parses an event line, [time, type, data]
+func parseCastEvent(data []byte) (CastEvent, error) {
+	var fields []json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return CastEvent{}, err
+	}
+	if len(fields) != 3 {
+		return CastEvent{}, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+
+	var seconds float64
+	var event CastEvent
+	if err := json.Unmarshal(fields[0], &seconds); err != nil {
+		return CastEvent{}, err
+	}
+	if err := json.Unmarshal(fields[1], &event.Type); err != nil {
+		return CastEvent{}, err
+	}
+	if err := json.Unmarshal(fields[2], &event.Data); err != nil {
+		return CastEvent{}, err
+	}
+	event.Time = time.Duration(seconds * float64(time.Second))
+	return event, nil
+}
+
+// Duration returns the time of the last event
+func (c *Cast) Duration() time.Duration {
+	if len(c.Events) == 0 {
+		return 0
+	}
+	return c.Events[len(c.Events)-1].Time
+}
+
+// screen replays the output of the recording up to the given time, or to
+// the end when at is 0, and returns the terminal screen
+func (c *Cast) screen(at time.Duration) *screen {
+	s := newScreen(Terminal{Columns: c.Header.Width, Rows: c.Header.Height})
+	for _, event := range c.Events {
+		if at > 0 && event.Time > at {
+			break
+		}
+		switch event.Type {
+		case CastOutput:
+			_, _ = s.Write([]byte(event.Data))
+		case CastResize:
+			if columns, rows, ok := parseCastSize(event.Data); ok {
+				s.resize(columns, rows)
+			}
+		}
+	}
+	s.crop()
+	return s
+}
+
+// parseCastSize parses the terminal size of a resize event, "COLUMNSxROWS"
+func parseCastSize(data string) (int, int, bool) {
+	width, height, ok := strings.Cut(data, "x")
+	columns, err := strconv.Atoi(width)
+	if !ok || err != nil || columns < 0 {
+		return 0, 0, false
+	}
+	rows, err := strconv.Atoi(height)
+	if err != nil || rows < 0 {
+		return 0, 0, false
+	}
+	return columns, rows, true
+}
+
+// palette returns the terminal palette of the recording's theme
+func (h CastHeader) palette() (TerminalPalette, bool) {
+	if h.Theme == nil {
+		return TerminalPalette{}, false
+	}
+
+	tp := TerminalPalette{
+		Foreground: h.Theme.Foreground,
+		Background: h.Theme.Background,
+	}
+	colors := strings.Split(h.Theme.Palette, ":")
+	for i := range tp.Colors {
+		switch {
+		case i < len(colors):
+			tp.Colors[i] = colors[i]
+		case len(colors) == 8:
+			// 8 color palettes have no bright variants
+			tp.Colors[i] = colors[i-8]
+		}
+	}
+	tp, err := tp.normalize()
+	return tp, err == nil
+}
@@ -0,0 +1,72 @@
+package freezelib
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+const testCast = `{"version": 2, "width": 20, "height": 3, "theme": {"fg": "#d0d0d0", "bg": "#101010", "palette": "#000000:#aa0000:#00aa00:#aaaa00:#0000aa:#aa00aa:#00aaaa:#aaaaaa"}}
+[0.5, "o", "$ make\r\n"]
+[1.0, "o", "\u001b[32mbuilding"]
+[1.5, "i", "q"]
+[2.0, "o", "\rdone    \u001b[0m\r\n"]
+[2.5, "m", "finished"]
+`
+
+func TestParseCast(t *testing.T) {
+	cast, err := ParseCast(strings.NewReader(testCast))
+	if err != nil {
+		t.Fatalf("ParseCast failed: %v", err)
+	}
+	if cast.Header.Width != 20 || cast.Header.Height != 3 {
+		t.Errorf("ParseCast() size = %dx%d, want 20x3", cast.Header.Width, cast.Header.Height)
+	}
+	if len(cast.Events) != 5 || cast.Events[1].Time != time.Second || cast.Events[1].Data != "\x1b[32mbuilding" {
+		t.Errorf("ParseCast() events = %+v", cast.Events)
+	}
+	if cast.Duration() != 2500*time.Millisecond {
+		t.Errorf("Duration() = %v, want 2.5s", cast.Duration())
+	}
+
+	tp, _ := cast.Header.palette()
+	if tp.Colors[2] != "#00aa00" || tp.Colors[10] != "#00aa00" || tp.Background != "#101010" {
+		t.Errorf("palette() = %+v", tp)
+	}
+
+	for _, invalid := range []string{"", `{"version": 1}`, `{"version": 2}` + "\n[1.0, \"o\"]"} {
+		if _, err := ParseCast(strings.NewReader(invalid)); err == nil {
+			t.Errorf("ParseCast(%q) should fail", invalid)
+		}
+	}
+}
+
+func TestGenerateFromCast(t *testing.T) {
+	tests := []struct {
+		name       string
+		at         time.Duration
+		expected   []string
+		unexpected []string
+	}{
+		{"Middle", 1200 * time.Millisecond, []string{`fill="#00aa00">building`, `fill="#101010"`}, []string{"done"}},
+		{"End", 0, []string{`fill="#00aa00">done`}, []string{"building"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svgData, err := NewGenerator(DefaultConfig()).GenerateFromCast(strings.NewReader(testCast), tt.at)
+			if err != nil {
+				t.Fatalf("GenerateFromCast failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(svgData), expected) {
+					t.Errorf("SVG should contain %s", expected)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(string(svgData), unexpected) {
+					t.Errorf("SVG should not contain %s", unexpected)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+
+	"github.com/alecthomas/chroma/v2"
 )
 
 // Config represents the configuration for generating code screenshots
@@ -17,9 +19,10 @@ type Config struct {
 	Height     float64   `json:"height"`
 
 	// Language and theme
-	Language string `json:"language"`
-	Theme    string `json:"theme"`
-	Wrap     int    `json:"wrap"`
+	Language       string                `json:"language"`
+	Theme          string                `json:"theme"`
+	ThemeOverrides map[string]TokenStyle `json:"theme_overrides"`
+	Wrap           int                   `json:"wrap"`
 
 	// Decoration
 	Border Border `json:"border"`
@@ -32,6 +35,30 @@ type Config struct {
 	LineHeight      float64 `json:"line_height"`
 	Lines           []int   `json:"lines"`
 	ShowLineNumbers bool    `json:"show_line_numbers"`
+
+	// Source normalization
+	Normalize Normalize `json:"normalize"`
+
+	// Secret redaction
+	Redact Redact `json:"redact"`
+
+	// Colors used for ANSI output
+	TerminalPalette TerminalPalette `json:"terminal_palette"`
+
+	// OSC 8 hyperlinks in ANSI output
+	Hyperlinks Hyperlinks `json:"hyperlinks"`
+
+	// Terminal size emulated for ANSI output
+	Terminal Terminal `json:"terminal"`
+
+	// Playback of animated output
+	Animation Animation `json:"animation"`
+
+	// Shell prompt lines prepended to ANSI output
+	Prompt Prompt `json:"prompt"`
+
+	// Terminal cursor drawn on ANSI output
+	Cursor Cursor `json:"cursor"`
 }
 
 // Shadow configuration for drop shadow effects
@@ -50,10 +77,40 @@ type Border struct {
 
 // Font configuration
 type Font struct {
-	Family    string  `json:"family"`
-	File      string  `json:"file"`
-	Size      float64 `json:"size"`
-	Ligatures bool    `json:"ligatures"`
+	Family string  `json:"family"`
+	File   string  `json:"file"`
+	Size   float64 `json:"size"`
+	// Ligatures enables programming ligatures. PNG output can only disable
+	// them for the bundled JetBrains Mono, other fonts keep their ligatures
+	// with a warning in the render result.
+	Ligatures bool `json:"ligatures"`
+	// Bold, Italic and BoldItalic are the font files for the styled
+	// variants of File, used for bold keywords, italic comments and ANSI
+	// text attributes
+	Bold       string `json:"bold"`
+	Italic     string `json:"italic"`
+	BoldItalic string `json:"bold_italic"`
+	// Fallbacks are font files or family names used for glyphs missing
+	// from the main font, such as CJK, emoji and symbols
+	Fallbacks []string `json:"fallbacks"`
+	// Embed embeds the bundled or registered font matching Family into
+	// SVG output when no font file is set, so SVGs render the same
+	// everywhere
+	Embed bool `json:"embed"`
+	// EmbedSystem also embeds an installed font matching Family. Installed
+	// fonts are not embedded by default, as their licenses may not allow
+	// redistribution.
+	EmbedSystem bool `json:"embed_system"`
+	// Subset limits embedded fonts to the glyphs used in the rendered text
+	Subset bool `json:"subset"`
+	// WOFF2 compresses embedded fonts into the WOFF2 format
+	WOFF2 bool `json:"woff2"`
+	// Dirs are font directories searched for Family in addition to the
+	// standard system font directories
+	Dirs []string `json:"dirs"`
+	// Strict fails rendering when Family cannot be found instead of
+	// falling back to the default font with a warning
+	Strict bool `json:"strict"`
 }
 
 // DefaultConfig returns a default configuration
@@ -70,7 +127,7 @@ func DefaultConfig() *Config {
 		Wrap:            0,
 		Border:          Border{Radius: 0, Width: 0, Color: "#515151"},
 		Shadow:          Shadow{Blur: 0, X: 0, Y: 0},
-		Font:            Font{Family: "JetBrains Mono", Size: 14, Ligatures: true},
+		Font:            Font{Family: "JetBrains Mono", Size: 14, Ligatures: true, Embed: true, Subset: true, WOFF2: true},
 		LineHeight:      1.2,
 		Lines:           []int{},
 		ShowLineNumbers: false,
@@ -98,12 +155,64 @@ func (c *Config) SetFont(family string, size float64) *Config {
 	return c
 }
 
+// SetFontVariants sets the font files for the bold, italic and bold italic variants
+func (c *Config) SetFontVariants(bold, italic, boldItalic string) *Config {
+	c.Font.Bold = bold
+	c.Font.Italic = italic
+	c.Font.BoldItalic = boldItalic
+	return c
+}
+
+// SetFontEmbedding sets whether the bundled font is embedded into SVG output
+// and whether embedded fonts are subset and compressed to WOFF2
+func (c *Config) SetFontEmbedding(embed, subset, woff2 bool) *Config {
+	c.Font.Embed = embed
+	c.Font.Subset = subset
+	c.Font.WOFF2 = woff2
+	return c
+}
+
+// SetSystemFontEmbedding sets whether an installed font matching the font
+// family is embedded into SVG output
+func (c *Config) SetSystemFontEmbedding(embed bool) *Config {
+	c.Font.EmbedSystem = embed
+	return c
+}
+
+// SetFontDirs sets additional directories searched for font families
+func (c *Config) SetFontDirs(dirs ...string) *Config {
+	c.Font.Dirs = dirs
+	return c
+}
+
+// SetFontStrict sets whether a missing font family is an error
+func (c *Config) SetFontStrict(strict bool) *Config {
+	c.Font.Strict = strict
+	return c
+}
+
+// SetFontFallbacks sets the fallback font files or family names
+func (c *Config) SetFontFallbacks(fallbacks ...string) *Config {
+	c.Font.Fallbacks = fallbacks
+	return c
+}
+
 // SetTheme sets the syntax highlighting theme
 func (c *Config) SetTheme(theme string) *Config {
 	c.Theme = theme
 	return c
 }
 
+// SetThemeOverride overrides the style of a token type (e.g. "Comment",
+// "Keyword" or "LineNumbers") on top of the selected theme
+func (c *Config) SetThemeOverride(tokenType string, style TokenStyle) *Config {
+	if c.ThemeOverrides == nil {
+		c.ThemeOverrides = make(map[string]TokenStyle)
+	}
+	c.ThemeOverrides[tokenType] = style
+	return c
+}
+
 // SetLanguage sets the programming language for syntax highlighting
 func (c *Config) SetLanguage(language string) *Config {
 	c.Language = language
@@ -155,6 +264,61 @@ func (c *Config) SetLines(start, end int) *Config {
 	return c
 }
 
+// SetNormalize sets the source normalization options
+func (c *Config) SetNormalize(normalize Normalize) *Config {
+	c.Normalize = normalize
+	return c
+}
+
+// SetRedact enables or disables secret redaction with the given mode
+func (c *Config) SetRedact(enabled bool, mode string) *Config {
+	c.Redact.Enabled = enabled
+	c.Redact.Mode = mode
+	return c
+}
+
+// AddRedactPattern adds a regular expression whose matches are redacted
+func (c *Config) AddRedactPattern(pattern string) *Config {
+	c.Redact.Patterns = append(c.Redact.Patterns, pattern)
+	return c
+}
+
+// SetTerminalPalette sets the colors used for ANSI output
+func (c *Config) SetTerminalPalette(palette TerminalPalette) *Config {
+	c.TerminalPalette = palette
+	return c
+}
+
+// SetHyperlinks sets how OSC 8 hyperlinks in ANSI output are rendered
+func (c *Config) SetHyperlinks(hyperlinks Hyperlinks) *Config {
+	c.Hyperlinks = hyperlinks
+	return c
+}
+
+// SetAnimation sets the playback of animated output
+func (c *Config) SetAnimation(animation Animation) *Config {
+	c.Animation = animation
+	return c
+}
+
+// SetPrompt sets the shell prompt lines prepended to ANSI output
+func (c *Config) SetPrompt(prompt Prompt) *Config {
+	c.Prompt = prompt
+	return c
+}
+
+// SetCursor sets the terminal cursor drawn on ANSI output
+func (c *Config) SetCursor(cursor Cursor) *Config {
+	c.Cursor = cursor
+	return c
+}
+
+// SetTerminal sets the size of the terminal emulated for ANSI output
+func (c *Config) SetTerminal(terminal Terminal) *Config {
+	c.Terminal = terminal
+	return c
+}
+
 // expandPadding expands padding values according to CSS rules
 func (c *Config) expandPadding(scale float64) []float64 {
 	p := c.Padding
@@ -194,6 +358,17 @@ func (c *Config) Clone() *Config {
 	copy(clone.Padding, c.Padding)
 	clone.Lines = make([]int, len(c.Lines))
 	copy(clone.Lines, c.Lines)
+	if c.ThemeOverrides != nil {
+		clone.ThemeOverrides = make(map[string]TokenStyle, len(c.ThemeOverrides))
+		for tokenType, style := range c.ThemeOverrides {
+			clone.ThemeOverrides[tokenType] = style
+		}
+	}
+	clone.Font.Fallbacks = append([]string(nil), c.Font.Fallbacks...)
+	clone.Font.Dirs = append([]string(nil), c.Font.Dirs...)
+	clone.Redact.Detectors = append([]string(nil), c.Redact.Detectors...)
+	clone.Redact.Patterns = append([]string(nil), c.Redact.Patterns...)
+	clone.Prompt.Commands = append([]string(nil), c.Prompt.Commands...)
 	return &clone
 }
 
@@ -208,6 +383,50 @@ func (c *Config) Validate() error {
 	if len(c.Lines) == 2 && c.Lines[0] > c.Lines[1] {
 		return fmt.Errorf("start line must be less than or equal to end line")
 	}
+	overrides, err := c.themeOverrides()
+	if err != nil {
+		return err
+	}
+	for _, override := range overrides {
+		if _, err := override.style.apply(chroma.StyleEntry{}); err != nil {
+			return fmt.Errorf("invalid theme override for %s: %w", override.tokenType, err)
+		}
+	}
+	if c.Terminal.Columns < 0 || c.Terminal.Rows < 0 {
+		return fmt.Errorf("terminal columns and rows must not be negative")
+	}
+	if c.Animation.Speed < 0 || c.Animation.IdleTimeLimit < 0 || c.Animation.Loops < 0 {
+		return fmt.Errorf("animation speed, idle time limit and loops must not be negative")
+	}
+	if _, err := c.TerminalPalette.normalize(); err != nil {
+		return err
+	}
+	if err := c.Prompt.validate(); err != nil {
+		return err
+	}
+	for _, color := range []struct{ name, value string }{
+		{"hyperlink", c.Hyperlinks.Color},
+		{"cursor", c.Cursor.Color},
+	} {
+		if _, ok := normalizeColor(strings.TrimSpace(color.value)); color.value != "" && !ok {
+			return fmt.Errorf("invalid %s color %q", color.name, color.value)
+		}
+	}
+	switch c.Cursor.Shape {
+	case "", CursorBlock, CursorUnderline, CursorBar:
+	default:
+		return fmt.Errorf("unknown cursor shape %q", c.Cursor.Shape)
+	}
+	if c.Redact.Enabled {
+		switch c.Redact.Mode {
+		case "", RedactMask, RedactBox, RedactBlur:
+		default:
+			return fmt.Errorf("unknown redaction mode %q", c.Redact.Mode)
+		}
+		if _, err := c.Redact.compile(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -217,12 +436,52 @@ func parseColor(color string) string {
 	if color == "" {
 		return "#000000"
 	}
+	if normalized, ok := normalizeColor(color); ok {
+		return normalized
+	}
 	if !strings.HasPrefix(color, "#") {
 		color = "#" + color
 	}
 	return color
 }
 
+// normalizeColor converts hex colors, with or without a leading #, and X11
+// "rgb:r/g/b" colors with 1 to 4 hex digits per component to #rrggbb
+func normalizeColor(color string) (string, bool) {
+	var components []string
+	if rgb, ok := strings.CutPrefix(color, "rgb:"); ok {
+		components = strings.Split(rgb, "/")
+		if len(components) != 3 {
+			return "", false
+		}
+	} else {
+		hex := strings.TrimPrefix(color, "#")
+		switch len(hex) {
+		case 3:
+			components = []string{hex[0:1], hex[1:2], hex[2:3]}
+		case 6:
+			components = []string{hex[0:2], hex[2:4], hex[4:6]}
+		default:
+			return "", false
+		}
+	}
+
+	var rgb [3]uint64
+	for i, component := range components {
+		if len(component) == 0 || len(component) > 4 {
+			return "", false
+		}
+		v, err := strconv.ParseUint(component, 16, 16)
+		if err != nil {
+			return "", false
+		}
+		// Scale the component to 8 bits
+		limit := uint64(1)<<(4*len(component)) - 1
+		rgb[i] = (v*255 + limit/2) / limit
+	}
+	return fmt.Sprintf("#%02x%02x%02x", rgb[0], rgb[1], rgb[2]), true
+}
+
 // dimensionToInt converts dimension strings to integers
 func dimensionToInt(dimension string) int {
 	dimension = strings.TrimSuffix(dimension, "px")
@@ -0,0 +1,130 @@
+package freezelib
+
+import (
+	"fmt"
+
+	"github.com/beevik/etree"
+	"github.com/charmbracelet/x/cellbuf"
+	"github.com/landaiqing/freezelib/svg"
+)
+
+// Cursor shapes
+const (
+	// CursorBlock covers the cell, showing its character in the background
+	// color
+	CursorBlock = "block"
+	// CursorUnderline is a line at the bottom of the cell
+	CursorUnderline = "underline"
+	// CursorBar is a line at the left edge of the cell
+	CursorBar = "bar"
+)
+
+// Cursor configures the terminal cursor drawn on ANSI output, at the final
+// cursor position or after the last character when that line is not shown.
+// Cursors hidden by the output are not drawn.
+type Cursor struct {
+	// Shape is one of "block", "underline" or "bar". No cursor is drawn
+	// when empty.
+	Shape string `json:"shape"`
+	// Color is the cursor color. The terminal palette's cursor color is
+	// used when empty, or its foreground color when that is not set.
+	Color string `json:"color"`
+	// Blink makes the cursor blink in SVG output
+	Blink bool `json:"blink"`
+}
+
+// placeCursor moves the cursor of the grid drawn from the source to the
+// given cursor position of the input, following wrapped lines. The cursor
+// is placed after the last character when its line was left out.
+func placeCursor(grid *screen, src source, row, col int) {
+	for i, n := range src.lineNumbers {
+		if n != row {
+			continue
+		}
+		for i+1 < len(src.lineNumbers) && src.lineNumbers[i+1] == -1 && i < len(grid.lines) && col >= len(grid.lines[i]) {
+			col -= len(grid.lines[i])
+			i++
+		}
+		grid.row, grid.col = i, col
+		return
+	}
+
+	grid.row = len(src.lineNumbers) - 1
+	if grid.row < 0 {
+		grid.row = 0
+	}
+	grid.col = 0
+	if grid.row < len(grid.lines) {
+		grid.col = len(grid.lines[grid.row])
+	}
+}
+
+// drawCursor draws the cursor of the grid into the text group
+func drawCursor(group *etree.Element, grid *screen, cursor Cursor, palette TerminalPalette, layout cellLayout) {
+	color := palette.Cursor
+	if cursor.Color != "" {
+		color = parseColor(cursor.Color)
+	}
+	if color == "" {
+		color = palette.Foreground
+	}
+
+	col := grid.col
+	if grid.columns > 0 && col >= grid.columns {
+		// the cursor waits at the right edge for the next character
+		col = grid.columns - 1
+	}
+	var line []cell
+	if grid.row < len(grid.lines) {
+		line = grid.lines[grid.row]
+	}
+	if col > 0 && col < len(line) && line[col].continuation() {
+		col--
+	}
+	var c cell
+	width := 1
+	if col < len(line) {
+		c = line[col]
+		if col+1 < len(line) && line[col+1].continuation() {
+			width = 2
+		}
+	}
+
+	x := layout.cellX(col)
+	y := layout.cellY(grid.row)
+	cellWidth := float64(width) * layout.cellWidth
+	g := group.CreateElement("g")
+	switch cursor.Shape {
+	case CursorBlock:
+		g.AddChild(svg.CreateRect(x, y, cellWidth, layout.lineHeight, color))
+		if c.Rune != 0 && c.Rune != ' ' && !c.style().has(cellbuf.ConcealAttr) {
+			text := g.CreateElement("text")
+			text.CreateAttr("xml:space", "preserve")
+			text.CreateAttr("x", fmt.Sprintf("%.2fpx", x))
+			text.CreateAttr("y", fmt.Sprintf("%.2fpx", y+layout.baseline))
+			text.CreateAttr("fill", palette.Background)
+			if c.style().has(cellbuf.BoldAttr) {
+				text.CreateAttr("font-weight", "bold")
+			}
+			if c.style().has(cellbuf.ItalicAttr) {
+				text.CreateAttr("font-style", "italic")
+			}
+			text.SetText(c.String())
+		}
+	case CursorUnderline:
+		height := layout.lineHeight / 10
+		g.AddChild(svg.CreateRect(x, y+layout.lineHeight-height, cellWidth, height, color))
+	case CursorBar:
+		g.AddChild(svg.CreateRect(x, y, layout.cellWidth/6, layout.lineHeight, color))
+	}
+
+	if cursor.Blink {
+		animate := g.CreateElement("animate")
+		animate.CreateAttr("attributeName", "opacity")
+		animate.CreateAttr("calcMode", "discrete")
+		animate.CreateAttr("values", "1;0")
+		animate.CreateAttr("keyTimes", "0;0.5")
+		animate.CreateAttr("dur", "1s")
+		animate.CreateAttr("repeatCount", "indefinite")
+	}
+}
@@ -0,0 +1,81 @@
+package freezelib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCursor(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     *Config
+		input      string
+		expected   []string
+		unexpected []string
+	}{
+		{
+			"Block",
+			DefaultConfig().SetCursor(Cursor{Shape: CursorBlock}),
+			"$ vim main.go\x1b[4D",
+			[]string{`<g><rect x="95.60" y="23.36" width="8.40" height="16.80" fill="#000000"/>`, `y="36.80px" fill="#ffffff">n</text>`},
+			nil,
+		},
+		{
+			"After the last character",
+			DefaultConfig().SetCursor(Cursor{Shape: CursorBar, Color: "#ff0000"}),
+			"$ ls",
+			[]string{`<rect x="53.60" y="`, `fill="#ff0000"`},
+			[]string{"<animate"},
+		},
+		{
+			"Wrapped line",
+			func() *Config {
+				config := DefaultConfig().SetCursor(Cursor{Shape: CursorUnderline})
+				config.Wrap = 4
+				return config
+			}(),
+			"one two\x1b[D",
+			[]string{`<rect x="36.80" y="55.28" width="8.40" height="1.68"`},
+			nil,
+		},
+		{
+			"Blink",
+			DefaultConfig().SetCursor(Cursor{Shape: CursorBlock, Blink: true}),
+			"$ ",
+			[]string{`<animate attributeName="opacity" calcMode="discrete" values="1;0"`},
+			nil,
+		},
+		{
+			"Hidden",
+			DefaultConfig().SetCursor(Cursor{Shape: CursorBlock, Color: "#ff0000"}),
+			"$ \x1b[?25l",
+			nil,
+			[]string{`fill="#ff0000"`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svgData, err := NewGenerator(tt.config).GenerateFromANSI(tt.input)
+			if err != nil {
+				t.Fatalf("GenerateFromANSI failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(svgData), expected) {
+					t.Errorf("SVG should contain %s", expected)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(string(svgData), unexpected) {
+					t.Errorf("SVG should not contain %s", unexpected)
+				}
+			}
+		})
+	}
+
+	if err := DefaultConfig().SetCursor(Cursor{Shape: "beam"}).Validate(); err == nil {
+		t.Error("Validate() should fail for unknown cursor shapes")
+	}
+	if err := DefaultConfig().SetCursor(Cursor{Color: "not a color"}).Validate(); err == nil {
+		t.Error("Validate() should fail for invalid cursor colors")
+	}
+}
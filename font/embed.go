@@ -0,0 +1,76 @@
+package font
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+)
+
+// EmbedOptions control how font data is embedded into SVG
+type EmbedOptions struct {
+	// Text is the rendered text, used to pick the glyphs to keep when subsetting
+	Text string
+	// Subset removes the glyphs that are not needed to render Text
+	Subset bool
+	// WOFF2 compresses the font into the WOFF2 format
+	WOFF2 bool
+	// Variant is the weight and style the font is declared with
+	Variant Variant
+}
+
+// PrepareEmbed subsets and compresses font data according to the options.
+// Only the first font of a TTC collection is embedded, as browsers do not
+// load collections. Fonts that cannot be subset or compressed, such as WOFF
+// files or fonts with CFF outlines, are passed through in their original
+// form.
+func PrepareEmbed(data []byte, opts EmbedOptions) ([]byte, error) {
+	data, err := ExtractFont(data, 0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to extract font: %w", err)
+	}
+	if opts.Subset {
+		subset, err := Subset(data, opts.Text)
+		switch {
+		case err == nil:
+			data = subset
+		case !errors.Is(err, ErrUnsupportedFont):
+			return nil, fmt.Errorf("failed to subset font: %w", err)
+		}
+	}
+	if opts.WOFF2 {
+		woff2, err := EncodeWOFF2(data)
+		switch {
+		case err == nil:
+			data = woff2
+		case !errors.Is(err, ErrUnsupportedFont):
+			return nil, fmt.Errorf("failed to encode WOFF2: %w", err)
+		}
+	}
+	return data, nil
+}
+
+// FontFace returns a CSS @font-face rule embedding the font data
+func FontFace(family string, data []byte, opts EmbedOptions) (string, error) {
+	data, err := PrepareEmbed(data, opts)
+	if err != nil {
+		return "", err
+	}
+	mime, format := fontFormat(data)
+	return fmt.Sprintf("@font-face { font-family: '%s'; src: url(data:%s;base64,%s) format('%s'); font-weight: %s; font-style: %s; }",
+		family, mime, base64.StdEncoding.EncodeToString(data), format, opts.Variant.Weight(), opts.Variant.Style()), nil
+}
+
+// fontFormat returns the MIME type and CSS format of font data
+func fontFormat(data []byte) (string, string) {
+	switch {
+	case bytes.HasPrefix(data, []byte("wOF2")):
+		return "font/woff2", "woff2"
+	case bytes.HasPrefix(data, []byte("wOFF")):
+		return "font/woff", "woff"
+	case bytes.HasPrefix(data, []byte("OTTO")):
+		return "font/otf", "opentype"
+	default:
+		return "font/ttf", "truetype"
+	}
+}
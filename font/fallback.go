@@ -0,0 +1,112 @@
+package font
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// Face is a font family together with its font data. Data is nil when the
+// family is only referenced by name.
+type Face struct {
+	Family string
+	Data   []byte
+}
+
+// fontExtensions are the file extensions recognised as font files
+var fontExtensions = map[string]bool{
+	".ttf":   true,
+	".otf":   true,
+	".ttc":   true,
+	".otc":   true,
+	".woff":  true,
+	".woff2": true,
+}
+
+// LoadFallbacks resolves font fallback entries. Entries naming a font file
+// are loaded and named after the family stored in the font; any other entry
+// is a family name, backed by a registered font or a font installed in the
+// system font directories or dirs when one exists.
+func LoadFallbacks(entries []string, dirs ...string) ([]Face, error) {
+	var faces []Face
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		if !isFontFile(entry) {
+			face := Face{Family: entry}
+			if resolution, err := Resolve(entry, dirs...); err == nil && resolution.Source != SourceDefault {
+				if fonts := resolution.Fonts(); len(fonts) > 0 {
+					face.Data = fonts[0].Data
+				}
+			}
+			faces = append(faces, face)
+			continue
+		}
+
+		data, err := os.ReadFile(entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fallback font: %w", err)
+		}
+		family, err := FamilyName(data)
+		if err != nil {
+			// Formats sfnt cannot parse are still usable by name
+			family = strings.TrimSuffix(filepath.Base(entry), filepath.Ext(entry))
+		}
+		faces = append(faces, Face{Family: family, Data: data})
+	}
+	return faces, nil
+}
+
+// isFontFile checks if a fallback entry refers to a font file
+func isFontFile(entry string) bool {
+	if fontExtensions[strings.ToLower(filepath.Ext(entry))] {
+		return true
+	}
+	info, err := os.Stat(entry)
+	return err == nil && !info.IsDir()
+}
+
+// FamilyName returns the family name stored in TTF, OTF or TTC font data
+func FamilyName(data []byte) (string, error) {
+	collection, err := sfnt.ParseCollection(data)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse font: %w", err)
+	}
+	f, err := collection.Font(0)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse font: %w", err)
+	}
+
+	var buf sfnt.Buffer
+	for _, id := range []sfnt.NameID{sfnt.NameIDTypographicFamily, sfnt.NameIDFamily} {
+		name, err := f.Name(&buf, id)
+		if err == nil && name != "" {
+			return name, nil
+		}
+	}
+	return "", fmt.Errorf("font has no family name")
+}
+
+// FamilyStack joins family names into a CSS font-family list ending with
+// the generic monospace family
+func FamilyStack(families ...string) string {
+	var stack []string
+	seen := map[string]bool{}
+	for _, family := range append(families, "monospace") {
+		if family == "" || seen[strings.ToLower(family)] {
+			continue
+		}
+		seen[strings.ToLower(family)] = true
+		if family != "monospace" && strings.ContainsAny(family, " ,") {
+			family = "'" + strings.ReplaceAll(family, "'", "") + "'"
+		}
+		stack = append(stack, family)
+	}
+	return strings.Join(stack, ", ")
+}
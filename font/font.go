@@ -3,6 +3,7 @@ package font
 import (
 	"embed"
 	"fmt"
+	"strings"
 
 	formatter "github.com/alecthomas/chroma/v2/formatters/svg"
 )
@@ -31,6 +32,50 @@ func init() {
 	}
 }
 
+// Variant identifies the weight and style of a font file
+type Variant int
+
+// Font variants
+const (
+	Regular Variant = iota
+	Bold
+	Italic
+	BoldItalic
+)
+
+// Variants lists all font variants
+var Variants = []Variant{Regular, Bold, Italic, BoldItalic}
+
+// Weight returns the CSS font-weight of the variant
+func (v Variant) Weight() string {
+	if v == Bold || v == BoldItalic {
+		return "bold"
+	}
+	return "normal"
+}
+
+// Style returns the CSS font-style of the variant
+func (v Variant) Style() string {
+	if v == Italic || v == BoldItalic {
+		return "italic"
+	}
+	return "normal"
+}
+
+// String returns the name of the variant
+func (v Variant) String() string {
+	switch v {
+	case Bold:
+		return "Bold"
+	case Italic:
+		return "Italic"
+	case BoldItalic:
+		return "Bold Italic"
+	default:
+		return "Regular"
+	}
+}
+
 // FontOptions creates formatter options for the given font configuration
 func FontOptions(family string, size float64, ligatures bool, fontFile string) ([]formatter.Option, error) {
 	var options []formatter.Option
@@ -62,26 +107,40 @@ func GetDefaultFontSize() float64 {
 	return 14.0
 }
 
-// IsMonospaceFont checks if a font family is monospace
-func IsMonospaceFont(family string) bool {
-	monospaceFonts := map[string]bool{
-		"JetBrains Mono":   true,
-		"Fira Code":        true,
-		"Source Code Pro":  true,
-		"Monaco":           true,
-		"Menlo":            true,
-		"Consolas":         true,
-		"Courier New":      true,
-		"monospace":        true,
-		"SF Mono":          true,
-		"Cascadia Code":    true,
-		"Ubuntu Mono":      true,
-		"DejaVu Sans Mono": true,
-		"Liberation Mono":  true,
-		"Inconsolata":      true,
-		"Roboto Mono":      true,
-	}
-	return monospaceFonts[family]
+// LigatureFeatures returns the CSS font-feature-settings value enabling or
+// disabling the OpenType ligature features
+func LigatureFeatures(enabled bool) string {
+	if enabled {
+		return `"liga" 1, "calt" 1`
+	}
+	return `"liga" 0, "calt" 0, "dlig" 0`
+}
+
+// NoLigaturesFamily returns the embedded family without ligatures for a
+// font family, such as JetBrains Mono NL for JetBrains Mono
+func NoLigaturesFamily(family string) (string, bool) {
+	switch family {
+	case "JetBrains Mono", "JetBrainsMono", "JetBrains Mono NL", "JetBrainsMonoNL":
+		return "JetBrains Mono NL", true
+	default:
+		return "", false
+	}
+}
+
+// IsMonospaceFont checks if a font family is monospace. Registered
+// families and families installed in the system font directories or dirs
+// are checked against their font data; the only other family known to be
+// monospace is the generic monospace family.
+func IsMonospaceFont(family string, dirs ...string) bool {
+	if strings.EqualFold(family, "monospace") {
+		return true
+	}
+	resolution, err := Resolve(family, dirs...)
+	if err != nil || resolution.Source == SourceDefault {
+		return false
+	}
+	fonts := resolution.Fonts()
+	return len(fonts) > 0 && fonts[0].Monospace
 }
 
 // ValidateFontFamily validates if a font family name is valid
@@ -103,14 +162,14 @@ func ValidateFontSize(size float64) error {
 	return nil
 }
 
-// GetFontHeightToWidthRatio returns the typical height to width ratio for monospace fonts
+// GetFontHeightToWidthRatio returns the height to width ratio of the default font
 func GetFontHeightToWidthRatio() float64 {
-	return 1.68
+	return DefaultMetrics().HeightToWidthRatio()
 }
 
-// CalculateTextWidth estimates the width of text in pixels
+// CalculateTextWidth returns the width of text in pixels using the default font
 func CalculateTextWidth(text string, fontSize float64) float64 {
-	return float64(len(text)) * (fontSize / GetFontHeightToWidthRatio())
+	return DefaultMetrics().TextWidth(text, fontSize)
 }
 
 // CalculateLineHeight calculates the line height in pixels
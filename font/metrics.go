@@ -0,0 +1,218 @@
+package font
+
+import (
+	"fmt"
+	"os"
+	"sync"
+
+	"github.com/mattn/go-runewidth"
+	xfont "golang.org/x/image/font"
+	"golang.org/x/image/font/sfnt"
+	"golang.org/x/image/math/fixed"
+)
+
+// Metrics describes the dimensions of a font. All values are in em units
+// and have to be multiplied by the font size to get pixels.
+type Metrics struct {
+	// Advance is the advance width of a terminal cell, taken from the "0" glyph
+	Advance float64
+	// Ascent is the distance from the baseline to the top of the line
+	Ascent float64
+	// Descent is the distance from the baseline to the bottom of the line
+	Descent float64
+	// LineGap is the recommended extra space between lines
+	LineGap float64
+	// UnderlineOffset is the distance from the baseline down to the
+	// center of an underline
+	UnderlineOffset float64
+	// StrikeoutOffset is the distance from the baseline up to the center
+	// of a strikethrough line
+	StrikeoutOffset float64
+	// OverlineOffset is the distance from the baseline up to the center of
+	// an overline, as far above capital letters as the underline is below
+	// the baseline
+	OverlineOffset float64
+	// LineThickness is the thickness of underlines and strikethrough lines
+	LineThickness float64
+
+	font       *sfnt.Font
+	unitsPerEm sfnt.Units
+}
+
+// fallbackMetrics approximate JetBrains Mono when no font data is available
+var fallbackMetrics = &Metrics{
+	Advance:         1 / 1.68,
+	Ascent:          1.02,
+	Descent:         0.3,
+	UnderlineOffset: 0.12,
+	StrikeoutOffset: 0.28,
+	OverlineOffset:  0.85,
+	LineThickness:   0.05,
+}
+
+var (
+	defaultMetrics     *Metrics
+	defaultMetricsOnce sync.Once
+)
+
+// DefaultMetrics returns the metrics of the embedded JetBrains Mono font
+func DefaultMetrics() *Metrics {
+	defaultMetricsOnce.Do(func() {
+		m, err := ParseMetrics(JetBrainsMonoTTF)
+		if err != nil {
+			m = fallbackMetrics
+		}
+		defaultMetrics = m
+	})
+	return defaultMetrics
+}
+
+// LoadMetrics reads the metrics of a TTF, OTF or TTC font file
+func LoadMetrics(fontFile string) (*Metrics, error) {
+	data, err := os.ReadFile(fontFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font file: %w", err)
+	}
+	return ParseMetrics(data)
+}
+
+// ParseMetrics reads the metrics of TTF, OTF or TTC font data. For font
+// collections the first font is used.
+func ParseMetrics(data []byte) (*Metrics, error) {
+	collection, err := sfnt.ParseCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	f, err := collection.Font(0)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+
+	var buf sfnt.Buffer
+	upem := f.UnitsPerEm()
+	ppem := fixed.I(int(upem))
+	fm, err := f.Metrics(&buf, ppem, xfont.HintingNone)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read font metrics: %w", err)
+	}
+
+	m := &Metrics{
+		Ascent:     unitsToEm(fm.Ascent, upem),
+		Descent:    unitsToEm(fm.Descent, upem),
+		LineGap:    unitsToEm(fm.Height-fm.Ascent-fm.Descent, upem),
+		font:       f,
+		unitsPerEm: upem,
+	}
+	if m.LineGap < 0 {
+		m.LineGap = 0
+	}
+
+	m.UnderlineOffset = fallbackMetrics.UnderlineOffset
+	m.LineThickness = fallbackMetrics.LineThickness
+	if post := f.PostTable(); post != nil && post.UnderlineThickness > 0 {
+		m.UnderlineOffset = -float64(post.UnderlinePosition) / float64(upem)
+		m.LineThickness = float64(post.UnderlineThickness) / float64(upem)
+	}
+	m.StrikeoutOffset = fallbackMetrics.StrikeoutOffset
+	if fm.XHeight > 0 {
+		m.StrikeoutOffset = unitsToEm(fm.XHeight, upem) / 2
+	}
+	m.OverlineOffset = fallbackMetrics.OverlineOffset
+	if fm.CapHeight > 0 {
+		m.OverlineOffset = unitsToEm(fm.CapHeight, upem) + m.UnderlineOffset
+	}
+
+	m.Advance = m.glyphAdvance(&buf, '0')
+	if m.Advance <= 0 {
+		m.Advance = fallbackMetrics.Advance
+	}
+	return m, nil
+}
+
+// unitsToEm converts a value measured at one pixel per font unit to em units
+func unitsToEm(v fixed.Int26_6, upem sfnt.Units) float64 {
+	return float64(v) / 64 / float64(upem)
+}
+
+// glyphAdvance returns the advance width of a rune in em units, or 0 when
+// the font has no glyph for it
+func (m *Metrics) glyphAdvance(buf *sfnt.Buffer, r rune) float64 {
+	if m.font == nil {
+		return 0
+	}
+	idx, err := m.font.GlyphIndex(buf, r)
+	if err != nil || idx == 0 {
+		return 0
+	}
+	advance, err := m.font.GlyphAdvance(buf, idx, fixed.I(int(m.unitsPerEm)), xfont.HintingNone)
+	if err != nil {
+		return 0
+	}
+	return unitsToEm(advance, m.unitsPerEm)
+}
+
+// Monospace reports whether the font is fixed pitch, either as declared
+// in its post table or by having equally wide glyphs
+func (m *Metrics) Monospace() bool {
+	if m.font == nil {
+		return true
+	}
+	if post := m.font.PostTable(); post != nil && post.IsFixedPitch {
+		return true
+	}
+	var buf sfnt.Buffer
+	for _, r := range "iMW." {
+		if advance := m.glyphAdvance(&buf, r); advance != 0 && advance != m.Advance {
+			return false
+		}
+	}
+	return true
+}
+
+// HasGlyph reports whether the font has a glyph for the rune
+func (m *Metrics) HasGlyph(r rune) bool {
+	var buf sfnt.Buffer
+	return m.glyphAdvance(&buf, r) > 0
+}
+
+// CellWidth returns the width of a terminal cell in pixels
+func (m *Metrics) CellWidth(fontSize float64) float64 {
+	return m.Advance * fontSize
+}
+
+// RuneWidth returns the advance width of a rune in pixels. Runes missing
+// from the font occupy as many cells as they take in a terminal.
+func (m *Metrics) RuneWidth(r rune, fontSize float64) float64 {
+	var buf sfnt.Buffer
+	return m.runeWidth(&buf, r, fontSize)
+}
+
+func (m *Metrics) runeWidth(buf *sfnt.Buffer, r rune, fontSize float64) float64 {
+	if advance := m.glyphAdvance(buf, r); advance > 0 {
+		return advance * fontSize
+	}
+	return float64(runewidth.RuneWidth(r)) * m.CellWidth(fontSize)
+}
+
+// TextWidth returns the width of text in pixels
+func (m *Metrics) TextWidth(text string, fontSize float64) float64 {
+	var buf sfnt.Buffer
+	var width float64
+	for _, r := range text {
+		width += m.runeWidth(&buf, r, fontSize)
+	}
+	return width
+}
+
+// HeightToWidthRatio returns the ratio of the font size to the cell width
+func (m *Metrics) HeightToWidthRatio() float64 {
+	return 1 / m.Advance
+}
+
+// BaselineOffset returns the distance in pixels from the top of a line box
+// of the given height to the baseline, splitting the leading evenly above
+// and below the glyphs like CSS does
+func (m *Metrics) BaselineOffset(fontSize, lineHeight float64) float64 {
+	halfLeading := (lineHeight - (m.Ascent+m.Descent)*fontSize) / 2
+	return halfLeading + m.Ascent*fontSize
+}
@@ -0,0 +1,154 @@
+package font
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// RegisteredFont is a font registered for use by family name
+type RegisteredFont struct {
+	Family    string
+	Variant   Variant
+	Data      []byte
+	Monospace bool
+}
+
+// registry holds the registered fonts, keyed by lower-cased family name
+var registry = struct {
+	sync.RWMutex
+	fonts map[string]map[Variant]RegisteredFont
+}{fonts: map[string]map[Variant]RegisteredFont{}}
+
+// bundledFonts are the font files embedded in the package, registered by
+// family and variant. Variants without a file are synthesized from the
+// regular font unless registered or configured as files.
+var bundledFonts = []struct {
+	file    string
+	family  string
+	variant Variant
+}{
+	{"JetBrainsMono-Regular.ttf", "JetBrains Mono", Regular},
+	{"JetBrainsMono-Bold.ttf", "JetBrains Mono", Bold},
+	{"JetBrainsMono-Italic.ttf", "JetBrains Mono", Italic},
+	{"JetBrainsMono-BoldItalic.ttf", "JetBrains Mono", BoldItalic},
+	{"JetBrainsMonoNL-Regular.ttf", "JetBrains Mono NL", Regular},
+}
+
+func init() {
+	for _, f := range bundledFonts {
+		if data, err := fonts.ReadFile(f.file); err == nil {
+			_ = Register(f.family, f.variant, data)
+		}
+	}
+}
+
+// Register makes font data available by family name to both SVG and PNG
+// output. An empty family uses the family name stored in the font.
+// Registering a family and variant again replaces the previous font.
+func Register(family string, variant Variant, data []byte) error {
+	metrics, err := ParseMetrics(data)
+	if err != nil {
+		return err
+	}
+	if family == "" {
+		if family, err = FamilyName(data); err != nil {
+			return err
+		}
+	}
+
+	key := strings.ToLower(family)
+	registry.Lock()
+	defer registry.Unlock()
+	if registry.fonts[key] == nil {
+		registry.fonts[key] = map[Variant]RegisteredFont{}
+	}
+	registry.fonts[key][variant] = RegisteredFont{
+		Family:    family,
+		Variant:   variant,
+		Data:      data,
+		Monospace: metrics.Monospace(),
+	}
+	return nil
+}
+
+// RegisterFile registers the font stored in a TTF, OTF or TTC file
+func RegisterFile(family string, variant Variant, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read font file: %w", err)
+	}
+	if err := Register(family, variant, data); err != nil {
+		return fmt.Errorf("failed to register %s: %w", path, err)
+	}
+	return nil
+}
+
+// Unregister removes all variants of a font family from the registry
+func Unregister(family string) {
+	registry.Lock()
+	defer registry.Unlock()
+	delete(registry.fonts, strings.ToLower(family))
+}
+
+// Lookup returns the registered font for a family and variant
+func Lookup(family string, variant Variant) (RegisteredFont, bool) {
+	registry.RLock()
+	defer registry.RUnlock()
+	f, ok := registry.fonts[strings.ToLower(family)][variant]
+	return f, ok
+}
+
+// IsRegistered checks if any variant of a font family is registered
+func IsRegistered(family string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	return len(registry.fonts[strings.ToLower(family)]) > 0
+}
+
+// RegisteredFamilies returns the names of the registered font families
+func RegisteredFamilies() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	var families []string
+	for _, variants := range registry.fonts {
+		for _, f := range variants {
+			families = append(families, f.Family)
+			break
+		}
+	}
+	sort.Strings(families)
+	return families
+}
+
+// RegisteredFonts returns all registered fonts ordered by family and variant
+func RegisteredFonts() []RegisteredFont {
+	registry.RLock()
+	defer registry.RUnlock()
+	var fonts []RegisteredFont
+	for _, variants := range registry.fonts {
+		for _, f := range variants {
+			fonts = append(fonts, f)
+		}
+	}
+	sort.Slice(fonts, func(i, j int) bool {
+		if fonts[i].Family != fonts[j].Family {
+			return fonts[i].Family < fonts[j].Family
+		}
+		return fonts[i].Variant < fonts[j].Variant
+	})
+	return fonts
+}
+
+// MonospaceFamilies returns the names of the registered monospace families
+func MonospaceFamilies() []string {
+	var families []string
+	for _, family := range RegisteredFamilies() {
+		if IsMonospaceFont(family) {
+			families = append(families, family)
+		}
+	}
+	return families
+}
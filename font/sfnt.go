@@ -0,0 +1,184 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// ErrUnsupportedFont is returned when font data cannot be subset or converted
+var ErrUnsupportedFont = errors.New("unsupported font format")
+
+// sfnt versions
+const (
+	sfntTrueType = 0x00010000
+	sfntApple    = 0x74727565 // "true"
+	sfntCFF      = 0x4f54544f // "OTTO"
+)
+
+// readTables splits TTF or OTF font data into its tables
+func readTables(data []byte) (uint32, map[string][]byte, error) {
+	return readTablesAt(data, 0)
+}
+
+// readTablesAt splits the font whose table directory starts at offset into
+// its tables. Table offsets are relative to the start of data, as in TTC
+// collections.
+func readTablesAt(data []byte, offset int) (uint32, map[string][]byte, error) {
+	if offset < 0 || len(data) < offset+12 {
+		return 0, nil, fmt.Errorf("%w: font data too short", ErrUnsupportedFont)
+	}
+	dir := data[offset:]
+	version := binary.BigEndian.Uint32(dir)
+	switch version {
+	case sfntTrueType, sfntApple, sfntCFF:
+	default:
+		return 0, nil, fmt.Errorf("%w: unknown sfnt version %#x", ErrUnsupportedFont, version)
+	}
+
+	numTables := int(binary.BigEndian.Uint16(dir[4:]))
+	if len(dir) < 12+16*numTables {
+		return 0, nil, fmt.Errorf("%w: truncated table directory", ErrUnsupportedFont)
+	}
+
+	tables := make(map[string][]byte, numTables)
+	for i := 0; i < numTables; i++ {
+		record := dir[12+16*i:]
+		tag := string(record[:4])
+		offset := int(binary.BigEndian.Uint32(record[8:]))
+		length := int(binary.BigEndian.Uint32(record[12:]))
+		if offset < 0 || length < 0 || offset+length > len(data) {
+			return 0, nil, fmt.Errorf("%w: table %q out of bounds", ErrUnsupportedFont, tag)
+		}
+		tables[tag] = data[offset : offset+length]
+	}
+	return version, tables, nil
+}
+
+// IsCollection reports whether font data is a TTC font collection
+func IsCollection(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("ttcf"))
+}
+
+// ExtractFont returns the font at index in TTC collection data as TTF or
+// OTF font data. Other font data is returned unchanged.
+func ExtractFont(data []byte, index int) ([]byte, error) {
+	if !IsCollection(data) {
+		return data, nil
+	}
+	if len(data) < 12 {
+		return nil, fmt.Errorf("%w: truncated collection header", ErrUnsupportedFont)
+	}
+	numFonts := int(binary.BigEndian.Uint32(data[8:]))
+	if index < 0 || index >= numFonts || len(data) < 12+4*numFonts {
+		return nil, fmt.Errorf("%w: no font %d in collection", ErrUnsupportedFont, index)
+	}
+	version, tables, err := readTablesAt(data, int(binary.BigEndian.Uint32(data[12+4*index:])))
+	if err != nil {
+		return nil, err
+	}
+	return writeTables(version, tables), nil
+}
+
+// writeTables assembles tables into TTF or OTF font data, updating the
+// table checksums and the checksum adjustment in the head table
+func writeTables(version uint32, tables map[string][]byte) []byte {
+	tags := sortedTags(tables)
+	numTables := len(tags)
+
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := (1 << entrySelector) * 16
+
+	size := 12 + 16*numTables
+	for _, tag := range tags {
+		size += pad4(len(tables[tag]))
+	}
+
+	out := make([]byte, 12+16*numTables, size)
+	binary.BigEndian.PutUint32(out, version)
+	binary.BigEndian.PutUint16(out[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(numTables*16-searchRange))
+
+	headOffset := -1
+	for i, tag := range tags {
+		data := tables[tag]
+		if tag == "head" && len(data) >= 12 {
+			// The checksum adjustment is computed over the whole font
+			data = append([]byte(nil), data...)
+			binary.BigEndian.PutUint32(data[8:], 0)
+			headOffset = len(out)
+		}
+
+		record := out[12+16*i:]
+		copy(record, tag)
+		binary.BigEndian.PutUint32(record[4:], checksum(data))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(data)))
+
+		out = append(out, data...)
+		out = append(out, make([]byte, pad4(len(data))-len(data))...)
+	}
+
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-checksum(out))
+	}
+	return out
+}
+
+// sortedTags returns the table tags in ascending order
+func sortedTags(tables map[string][]byte) []string {
+	tags := make([]string, 0, len(tables))
+	for tag := range tables {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags
+}
+
+// checksum computes an sfnt table checksum
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
+
+// pad4 rounds n up to a multiple of four
+func pad4(n int) int {
+	return (n + 3) &^ 3
+}
+
+// fontData is a bounds-checked view of a font table. Reads outside the
+// table return zero so malformed fonts cannot cause panics.
+type fontData []byte
+
+func (b fontData) u16(offset int) int {
+	if offset < 0 || offset+2 > len(b) {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b[offset:]))
+}
+
+func (b fontData) u32(offset int) int {
+	if offset < 0 || offset+4 > len(b) {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(b[offset:]))
+}
+
+func (b fontData) slice(offset int) fontData {
+	if offset < 0 || offset > len(b) {
+		return nil
+	}
+	return b[offset:]
+}
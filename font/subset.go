@@ -0,0 +1,397 @@
+package font
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// Subset returns a copy of TrueType font data that only contains the glyphs
+// needed to render text, including the glyphs reachable through ligatures
+// and other substitutions. Glyph IDs are kept so the layout tables stay
+// valid; unused glyphs are emptied. Fonts with CFF outlines return
+// ErrUnsupportedFont.
+func Subset(data []byte, text string) ([]byte, error) {
+	version, tables, err := readTables(data)
+	if err != nil {
+		return nil, err
+	}
+	if version == sfntCFF {
+		return nil, fmt.Errorf("%w: CFF outlines cannot be subset", ErrUnsupportedFont)
+	}
+	head, glyf, loca := tables["head"], tables["glyf"], tables["loca"]
+	if len(head) < 54 || glyf == nil || loca == nil {
+		return nil, fmt.Errorf("%w: missing glyf, loca or head table", ErrUnsupportedFont)
+	}
+
+	f, err := sfnt.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse font: %w", err)
+	}
+	numGlyphs := f.NumGlyphs()
+
+	longLoca := binary.BigEndian.Uint16(head[50:]) != 0
+	offsets, err := parseLoca(loca, longLoca, numGlyphs, len(glyf))
+	if err != nil {
+		return nil, err
+	}
+
+	// Collect the glyphs for the characters in text
+	keep := make([]bool, numGlyphs)
+	keep[0] = true // .notdef
+	cmap := map[rune]uint16{}
+	var buf sfnt.Buffer
+	for _, r := range text {
+		if _, ok := cmap[r]; ok {
+			continue
+		}
+		idx, err := f.GlyphIndex(&buf, r)
+		if err != nil || idx == 0 || int(idx) >= numGlyphs {
+			continue
+		}
+		cmap[r] = uint16(idx)
+		keep[idx] = true
+	}
+
+	closeSubstitutions(fontData(tables["GSUB"]), keep)
+	closeComposites(glyf, offsets, keep)
+
+	// Rebuild glyf and loca with the unused glyphs emptied
+	var newGlyf []byte
+	newLoca := make([]byte, 0, len(loca))
+	for i := 0; i < numGlyphs; i++ {
+		newLoca = appendLocaOffset(newLoca, len(newGlyf), longLoca)
+		if keep[i] {
+			newGlyf = append(newGlyf, glyf[offsets[i]:offsets[i+1]]...)
+			if !longLoca && len(newGlyf)%2 != 0 {
+				newGlyf = append(newGlyf, 0)
+			}
+		}
+	}
+	newLoca = appendLocaOffset(newLoca, len(newGlyf), longLoca)
+
+	subset := make(map[string][]byte, len(tables))
+	for tag, table := range tables {
+		switch tag {
+		case "DSIG":
+			// The signature no longer matches the font
+		default:
+			subset[tag] = table
+		}
+	}
+	subset["glyf"] = newGlyf
+	subset["loca"] = newLoca
+	subset["cmap"] = buildCmap(cmap)
+	if post := tables["post"]; len(post) >= 32 {
+		// Version 3 drops the glyph names
+		post = append([]byte(nil), post[:32]...)
+		binary.BigEndian.PutUint32(post, 0x00030000)
+		subset["post"] = post
+	}
+
+	return writeTables(version, subset), nil
+}
+
+// parseLoca reads the glyph offsets from the loca table
+func parseLoca(loca []byte, long bool, numGlyphs, glyfLength int) ([]int, error) {
+	size := 2
+	if long {
+		size = 4
+	}
+	if len(loca) < (numGlyphs+1)*size {
+		return nil, fmt.Errorf("%w: truncated loca table", ErrUnsupportedFont)
+	}
+
+	offsets := make([]int, numGlyphs+1)
+	for i := range offsets {
+		if long {
+			offsets[i] = int(binary.BigEndian.Uint32(loca[i*4:]))
+		} else {
+			offsets[i] = int(binary.BigEndian.Uint16(loca[i*2:])) * 2
+		}
+		if offsets[i] > glyfLength || (i > 0 && offsets[i] < offsets[i-1]) {
+			return nil, fmt.Errorf("%w: invalid loca offset for glyph %d", ErrUnsupportedFont, i)
+		}
+	}
+	return offsets, nil
+}
+
+// appendLocaOffset appends a glyph offset in the short or long loca format
+func appendLocaOffset(loca []byte, offset int, long bool) []byte {
+	if long {
+		return binary.BigEndian.AppendUint32(loca, uint32(offset))
+	}
+	return binary.BigEndian.AppendUint16(loca, uint16(offset/2))
+}
+
+// Composite glyph flags
+const (
+	argsAreWords    = 0x0001
+	haveScale       = 0x0008
+	moreComponents  = 0x0020
+	haveXYScale     = 0x0040
+	haveTwoByTwo    = 0x0080
+	compositeHeader = 10
+)
+
+// closeComposites marks the components of every kept composite glyph
+func closeComposites(glyf []byte, offsets []int, keep []bool) {
+	var visit func(glyph int)
+	visit = func(glyph int) {
+		g := fontData(glyf[offsets[glyph]:offsets[glyph+1]])
+		if len(g) < compositeHeader || int16(g.u16(0)) >= 0 {
+			return
+		}
+
+		offset := compositeHeader
+		for {
+			flags := g.u16(offset)
+			component := g.u16(offset + 2)
+			if component < len(keep) && !keep[component] {
+				keep[component] = true
+				visit(component)
+			}
+
+			offset += 4
+			if flags&argsAreWords != 0 {
+				offset += 4
+			} else {
+				offset += 2
+			}
+			switch {
+			case flags&haveScale != 0:
+				offset += 2
+			case flags&haveXYScale != 0:
+				offset += 4
+			case flags&haveTwoByTwo != 0:
+				offset += 8
+			}
+			if flags&moreComponents == 0 || offset >= len(g) {
+				return
+			}
+		}
+	}
+
+	for glyph := range keep {
+		if keep[glyph] {
+			visit(glyph)
+		}
+	}
+}
+
+// GSUB lookup types
+const (
+	gsubSingle    = 1
+	gsubMultiple  = 2
+	gsubAlternate = 3
+	gsubLigature  = 4
+	gsubExtension = 7
+)
+
+// closeSubstitutions marks every glyph the GSUB table can substitute for
+// the kept glyphs. Lookups are applied conservatively regardless of the
+// features and contexts that trigger them.
+func closeSubstitutions(gsub fontData, keep []bool) {
+	if len(gsub) < 10 {
+		return
+	}
+
+	type subtable struct {
+		lookupType int
+		data       fontData
+		coverage   []int
+	}
+	var subtables []subtable
+	lookupList := gsub.slice(gsub.u16(8))
+	for i := 0; i < lookupList.u16(0); i++ {
+		lookup := lookupList.slice(lookupList.u16(2 + 2*i))
+		lookupType := lookup.u16(0)
+		for j := 0; j < lookup.u16(4); j++ {
+			st := lookup.slice(lookup.u16(6 + 2*j))
+			subtableType := lookupType
+			if lookupType == gsubExtension {
+				subtableType, st = st.u16(2), st.slice(st.u32(4))
+			}
+			if subtableType < gsubSingle || subtableType > gsubLigature {
+				// Contextual lookups only reference other lookups
+				continue
+			}
+			subtables = append(subtables, subtable{subtableType, st, coverage(st.slice(st.u16(2)))})
+		}
+	}
+
+	mark := func(glyph int) bool {
+		if glyph < len(keep) && !keep[glyph] {
+			keep[glyph] = true
+			return true
+		}
+		return false
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, st := range subtables {
+			format := st.data.u16(0)
+			for index, glyph := range st.coverage {
+				if glyph >= len(keep) || !keep[glyph] {
+					continue
+				}
+				switch st.lookupType {
+				case gsubSingle:
+					if format == 1 {
+						changed = mark((glyph+st.data.u16(4))&0xFFFF) || changed
+					} else if index < st.data.u16(4) {
+						changed = mark(st.data.u16(6+2*index)) || changed
+					}
+				case gsubMultiple, gsubAlternate:
+					if index >= st.data.u16(4) {
+						continue
+					}
+					sequence := st.data.slice(st.data.u16(6 + 2*index))
+					for k := 0; k < sequence.u16(0); k++ {
+						changed = mark(sequence.u16(2+2*k)) || changed
+					}
+				case gsubLigature:
+					if index >= st.data.u16(4) {
+						continue
+					}
+					set := st.data.slice(st.data.u16(6 + 2*index))
+					for k := 0; k < set.u16(0); k++ {
+						ligature := set.slice(set.u16(2 + 2*k))
+						if ligatureComponentsKept(ligature, keep) {
+							changed = mark(ligature.u16(0)) || changed
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+// ligatureComponentsKept checks if all components after the first of a
+// ligature are kept
+func ligatureComponentsKept(ligature fontData, keep []bool) bool {
+	for k := 1; k < ligature.u16(2); k++ {
+		component := ligature.u16(2 + 2*k)
+		if component >= len(keep) || !keep[component] {
+			return false
+		}
+	}
+	return true
+}
+
+// coverage returns the glyphs of an OpenType coverage table in coverage index order
+func coverage(table fontData) []int {
+	var glyphs []int
+	switch table.u16(0) {
+	case 1:
+		for i := 0; i < table.u16(2); i++ {
+			glyphs = append(glyphs, table.u16(4+2*i))
+		}
+	case 2:
+		for i := 0; i < table.u16(2); i++ {
+			start, end := table.u16(4+6*i), table.u16(6+6*i)
+			for glyph := start; glyph <= end; glyph++ {
+				glyphs = append(glyphs, glyph)
+			}
+		}
+	}
+	return glyphs
+}
+
+// buildCmap creates a cmap table with a format 4 subtable for the Basic
+// Multilingual Plane and a format 12 subtable for all characters
+func buildCmap(mapping map[rune]uint16) []byte {
+	runes := make([]rune, 0, len(mapping))
+	for r := range mapping {
+		runes = append(runes, r)
+	}
+	sort.Slice(runes, func(i, j int) bool { return runes[i] < runes[j] })
+
+	// Group consecutive characters mapped to consecutive glyphs
+	type group struct {
+		start, end rune
+		glyph      uint16
+	}
+	var groups []group
+	for _, r := range runes {
+		glyph := mapping[r]
+		if n := len(groups); n > 0 && groups[n-1].end+1 == r && rune(groups[n-1].glyph)+r-groups[n-1].start == rune(glyph) {
+			groups[n-1].end = r
+			continue
+		}
+		groups = append(groups, group{start: r, end: r, glyph: glyph})
+	}
+
+	// Format 12
+	format12 := make([]byte, 16, 16+12*len(groups))
+	binary.BigEndian.PutUint16(format12, 12)
+	for _, g := range groups {
+		format12 = binary.BigEndian.AppendUint32(format12, uint32(g.start))
+		format12 = binary.BigEndian.AppendUint32(format12, uint32(g.end))
+		format12 = binary.BigEndian.AppendUint32(format12, uint32(g.glyph))
+	}
+	binary.BigEndian.PutUint32(format12[4:], uint32(len(format12)))
+	binary.BigEndian.PutUint32(format12[12:], uint32(len(groups)))
+
+	// Format 4, terminated by the mandatory 0xFFFF segment
+	var bmp []group
+	for _, g := range groups {
+		if g.end < 0xFFFF {
+			bmp = append(bmp, g)
+		}
+	}
+	bmp = append(bmp, group{start: 0xFFFF, end: 0xFFFF, glyph: 0})
+	segCount := len(bmp)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= segCount {
+		entrySelector++
+	}
+	searchRange := 2 << entrySelector
+
+	format4 := make([]byte, 14, 16+8*segCount)
+	binary.BigEndian.PutUint16(format4, 4)
+	binary.BigEndian.PutUint16(format4[6:], uint16(segCount*2))
+	binary.BigEndian.PutUint16(format4[8:], uint16(searchRange))
+	binary.BigEndian.PutUint16(format4[10:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(format4[12:], uint16(segCount*2-searchRange))
+	for _, g := range bmp {
+		format4 = binary.BigEndian.AppendUint16(format4, uint16(g.end))
+	}
+	format4 = append(format4, 0, 0) // reservedPad
+	for _, g := range bmp {
+		format4 = binary.BigEndian.AppendUint16(format4, uint16(g.start))
+	}
+	for _, g := range bmp {
+		delta := uint16(1)
+		if g.start != 0xFFFF {
+			delta = g.glyph - uint16(g.start)
+		}
+		format4 = binary.BigEndian.AppendUint16(format4, delta)
+	}
+	format4 = append(format4, make([]byte, 2*segCount)...) // idRangeOffset
+	binary.BigEndian.PutUint16(format4[2:], uint16(len(format4)))
+
+	subtables := [][]byte{format12}
+	records := [][2]uint16{{3, 10}}
+	if len(format4) <= 0xFFFF {
+		subtables = [][]byte{format4, format12}
+		records = [][2]uint16{{3, 1}, {3, 10}}
+	}
+
+	cmap := make([]byte, 4, 4+8*len(records))
+	binary.BigEndian.PutUint16(cmap[2:], uint16(len(records)))
+	offset := 4 + 8*len(records)
+	for i, record := range records {
+		cmap = binary.BigEndian.AppendUint16(cmap, record[0])
+		cmap = binary.BigEndian.AppendUint16(cmap, record[1])
+		cmap = binary.BigEndian.AppendUint32(cmap, uint32(offset))
+		offset += len(subtables[i])
+	}
+	for _, subtable := range subtables {
+		cmap = append(cmap, subtable...)
+	}
+	return cmap
+}
@@ -0,0 +1,294 @@
+package font
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/image/font/sfnt"
+)
+
+// ErrFamilyNotFound is returned when a font family is neither registered
+// nor installed
+var ErrFamilyNotFound = errors.New("font family not found")
+
+// SystemFont is an installed font file
+type SystemFont struct {
+	Family  string
+	Variant Variant
+	Path    string
+	// Index is the position of the font in a TTC collection file
+	Index int
+}
+
+// Source describes where a resolved font comes from
+type Source string
+
+// Font sources
+const (
+	SourceFile       Source = "file"
+	SourceRegistered Source = "registered"
+	SourceSystem     Source = "system"
+	SourceDefault    Source = "default"
+)
+
+// Resolution reports the font used to render a requested family
+type Resolution struct {
+	// Requested is the configured font family
+	Requested string
+	// Family is the font family actually used
+	Family string
+	Source Source
+	// Path is the font file for file and system fonts
+	Path string
+	// fonts are the variants of a system family, which are only loaded for
+	// the resolution instead of being registered
+	fonts []RegisteredFont
+}
+
+// Font returns a variant of the resolved family
+func (r Resolution) Font(variant Variant) (RegisteredFont, bool) {
+	if r.Source != SourceSystem {
+		return Lookup(r.Family, variant)
+	}
+	for _, f := range r.fonts {
+		if f.Variant == variant {
+			return f, true
+		}
+	}
+	return RegisteredFont{}, false
+}
+
+// Fonts returns the variants of the resolved family
+func (r Resolution) Fonts() []RegisteredFont {
+	var fonts []RegisteredFont
+	for _, variant := range Variants {
+		if f, ok := r.Font(variant); ok {
+			fonts = append(fonts, f)
+		}
+	}
+	return fonts
+}
+
+// systemFontsMaxAge is how long the fonts found in a directory are cached,
+// so that fonts installed later are found by long-running programs
+const systemFontsMaxAge = time.Minute
+
+// scannedDir is the cached result of scanning a font directory
+type scannedDir struct {
+	fonts   []SystemFont
+	scanned time.Time
+}
+
+// systemFonts caches the fonts found in each scanned directory
+var systemFonts = struct {
+	sync.Mutex
+	dirs map[string]scannedDir
+}{dirs: map[string]scannedDir{}}
+
+// SystemFontDirs returns the standard Linux font directories
+func SystemFontDirs() []string {
+	var dirs []string
+	home, _ := os.UserHomeDir()
+
+	dataHome := os.Getenv("XDG_DATA_HOME")
+	if dataHome == "" && home != "" {
+		dataHome = filepath.Join(home, ".local", "share")
+	}
+	if dataHome != "" {
+		dirs = append(dirs, filepath.Join(dataHome, "fonts"))
+	}
+	if home != "" {
+		dirs = append(dirs, filepath.Join(home, ".fonts"))
+	}
+
+	dataDirs := os.Getenv("XDG_DATA_DIRS")
+	if dataDirs == "" {
+		dataDirs = "/usr/local/share:/usr/share"
+	}
+	for _, dir := range filepath.SplitList(dataDirs) {
+		if dir != "" {
+			dirs = append(dirs, filepath.Join(dir, "fonts"))
+		}
+	}
+	return dirs
+}
+
+// SystemFonts returns the fonts installed in the standard font directories
+// and the given directories. The fonts found in a directory are cached for a
+// minute.
+func SystemFonts(dirs ...string) []SystemFont {
+	var found []SystemFont
+	seen := map[string]bool{}
+	for _, dir := range append(dirs, SystemFontDirs()...) {
+		dir = filepath.Clean(dir)
+		if seen[dir] {
+			continue
+		}
+		seen[dir] = true
+		found = append(found, scanFontDir(dir)...)
+	}
+	return found
+}
+
+// SystemFamilies returns the names of the installed font families
+func SystemFamilies(dirs ...string) []string {
+	var families []string
+	seen := map[string]bool{}
+	for _, f := range SystemFonts(dirs...) {
+		if !seen[strings.ToLower(f.Family)] {
+			seen[strings.ToLower(f.Family)] = true
+			families = append(families, f.Family)
+		}
+	}
+	sort.Strings(families)
+	return families
+}
+
+// FindSystemFont returns the installed font files of a family, at most one
+// per variant
+func FindSystemFont(family string, dirs ...string) []SystemFont {
+	var found []SystemFont
+	variants := map[Variant]bool{}
+	for _, f := range SystemFonts(dirs...) {
+		if strings.EqualFold(f.Family, family) && !variants[f.Variant] {
+			variants[f.Variant] = true
+			found = append(found, f)
+		}
+	}
+	return found
+}
+
+// Resolve finds the font for a family among the registered fonts and the
+// installed fonts. Installed fonts are loaded into the resolution without
+// being registered. When the family cannot be found, the default font is
+// reported together with an error wrapping ErrFamilyNotFound.
+func Resolve(family string, dirs ...string) (Resolution, error) {
+	resolution := Resolution{Requested: family, Family: family, Source: SourceRegistered}
+	if IsRegistered(family) {
+		return resolution, nil
+	}
+
+	if found := FindSystemFont(family, dirs...); len(found) > 0 {
+		for _, f := range found {
+			data, err := os.ReadFile(f.Path)
+			if err != nil {
+				return resolution, fmt.Errorf("failed to read font file: %w", err)
+			}
+			// Only the matching face of a collection is used, so that it
+			// can be embedded on its own
+			if data, err = ExtractFont(data, f.Index); err != nil {
+				return resolution, fmt.Errorf("failed to load %s: %w", f.Path, err)
+			}
+			metrics, err := ParseMetrics(data)
+			if err != nil {
+				return resolution, fmt.Errorf("failed to load %s: %w", f.Path, err)
+			}
+			resolution.fonts = append(resolution.fonts, RegisteredFont{
+				Family:    f.Family,
+				Variant:   f.Variant,
+				Data:      data,
+				Monospace: metrics.Monospace(),
+			})
+			if f.Variant == Regular || resolution.Path == "" {
+				resolution.Family, resolution.Path = f.Family, f.Path
+			}
+		}
+		resolution.Source = SourceSystem
+		return resolution, nil
+	}
+
+	resolution.Family = GetDefaultFontFamily()
+	resolution.Source = SourceDefault
+	if strings.EqualFold(family, "monospace") {
+		return resolution, nil
+	}
+	return resolution, fmt.Errorf("%w: %s", ErrFamilyNotFound, family)
+}
+
+// scanFontDir returns the fonts found in a directory and its subdirectories
+func scanFontDir(dir string) []SystemFont {
+	systemFonts.Lock()
+	defer systemFonts.Unlock()
+	if cached, ok := systemFonts.dirs[dir]; ok && time.Since(cached.scanned) < systemFontsMaxAge {
+		return cached.fonts
+	}
+
+	var found []SystemFont
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Skip unreadable directories
+			return nil
+		}
+		if d.IsDir() || !fontExtensions[strings.ToLower(filepath.Ext(path))] {
+			return nil
+		}
+		found = append(found, readSystemFont(path)...)
+		return nil
+	})
+	systemFonts.dirs[dir] = scannedDir{fonts: found, scanned: time.Now()}
+	return found
+}
+
+// readSystemFont reads the family and variant of each font in a file
+// without loading the whole file
+func readSystemFont(path string) []SystemFont {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil
+	}
+	defer file.Close()
+
+	collection, err := sfnt.ParseCollectionReaderAt(file)
+	if err != nil {
+		return nil
+	}
+
+	var found []SystemFont
+	var buf sfnt.Buffer
+	for i := 0; i < collection.NumFonts(); i++ {
+		f, err := collection.Font(i)
+		if err != nil {
+			continue
+		}
+		family := fontName(f, &buf, sfnt.NameIDTypographicFamily, sfnt.NameIDFamily)
+		variant, ok := parseVariant(fontName(f, &buf, sfnt.NameIDTypographicSubfamily, sfnt.NameIDSubfamily))
+		if family != "" && ok {
+			found = append(found, SystemFont{Family: family, Variant: variant, Path: path, Index: i})
+		}
+	}
+	return found
+}
+
+// fontName returns the first name found for the IDs
+func fontName(f *sfnt.Font, buf *sfnt.Buffer, ids ...sfnt.NameID) string {
+	for _, id := range ids {
+		if name, err := f.Name(buf, id); err == nil && name != "" {
+			return name
+		}
+	}
+	return ""
+}
+
+// parseVariant returns the variant of a font subfamily name. Weights other
+// than regular and bold are not supported.
+func parseVariant(subfamily string) (Variant, bool) {
+	switch strings.ToLower(strings.TrimSpace(subfamily)) {
+	case "regular", "normal", "book", "roman", "":
+		return Regular, true
+	case "bold":
+		return Bold, true
+	case "italic", "oblique":
+		return Italic, true
+	case "bold italic", "bold oblique":
+		return BoldItalic, true
+	default:
+		return Regular, false
+	}
+}
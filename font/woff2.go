@@ -0,0 +1,117 @@
+package font
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/andybalholm/brotli"
+)
+
+// woff2KnownTags are the table tags with a predefined index in WOFF2
+var woff2KnownTags = []string{
+	"cmap", "head", "hhea", "hmtx", "maxp", "name", "OS/2", "post",
+	"cvt ", "fpgm", "glyf", "loca", "prep", "CFF ", "VORG", "EBDT",
+	"EBLC", "gasp", "hdmx", "kern", "LTSH", "PCLT", "VDMX", "vhea",
+	"vmtx", "BASE", "GDEF", "GPOS", "GSUB", "EBSC", "JSTF", "MATH",
+	"CBDT", "CBLC", "COLR", "CPAL", "SVG ", "sbix", "acnt", "avar",
+	"bdat", "bloc", "bsln", "cvar", "fdsc", "feat", "fmtx", "fvar",
+	"gvar", "hsty", "just", "lcar", "mort", "morx", "opbd", "prop",
+	"trak", "Zapf", "Silf", "Glat", "Gloc", "Feat", "Sill",
+}
+
+const (
+	woff2HeaderSize = 48
+	// woff2ArbitraryTag marks a table tag stored after the flags byte
+	woff2ArbitraryTag = 63
+	// woff2NullTransform is the transform version meaning "not transformed"
+	// for the glyf and loca tables
+	woff2NullTransform = 3
+	// headLosslessFlag is the head flags bit set for transformed fonts
+	headLosslessFlag = 1 << 11
+	// woff2Quality trades a slightly larger output for much faster
+	// compression than brotli.BestCompression
+	woff2Quality = 9
+)
+
+// EncodeWOFF2 compresses TTF or OTF font data into the WOFF2 format. The
+// tables are stored without the optional glyf transform.
+func EncodeWOFF2(data []byte) ([]byte, error) {
+	version, tables, err := readTables(data)
+	if err != nil {
+		return nil, err
+	}
+
+	if head := tables["head"]; len(head) >= 18 {
+		head = append([]byte(nil), head...)
+		binary.BigEndian.PutUint16(head[16:], binary.BigEndian.Uint16(head[16:])|headLosslessFlag)
+		tables["head"] = head
+	}
+
+	tags := sortedTags(tables)
+	var directory, stream []byte
+	sfntSize := 12 + 16*len(tags)
+	for _, tag := range tags {
+		table := tables[tag]
+
+		index := woff2ArbitraryTag
+		for i, known := range woff2KnownTags {
+			if known == tag {
+				index = i
+				break
+			}
+		}
+		flags := byte(index)
+		if tag == "glyf" || tag == "loca" {
+			flags |= woff2NullTransform << 6
+		}
+		directory = append(directory, flags)
+		if index == woff2ArbitraryTag {
+			directory = append(directory, tag...)
+		}
+		directory = appendUIntBase128(directory, uint32(len(table)))
+
+		stream = append(stream, table...)
+		sfntSize += pad4(len(table))
+	}
+
+	var compressed bytes.Buffer
+	w := brotli.NewWriterLevel(&compressed, woff2Quality)
+	if _, err := w.Write(stream); err != nil {
+		return nil, fmt.Errorf("failed to compress font: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return nil, fmt.Errorf("failed to compress font: %w", err)
+	}
+
+	length := pad4(woff2HeaderSize + len(directory) + compressed.Len())
+	out := make([]byte, woff2HeaderSize, length)
+	copy(out, "wOF2")
+	binary.BigEndian.PutUint32(out[4:], version)
+	binary.BigEndian.PutUint32(out[8:], uint32(length))
+	binary.BigEndian.PutUint16(out[12:], uint16(len(tags)))
+	binary.BigEndian.PutUint32(out[16:], uint32(sfntSize))
+	binary.BigEndian.PutUint32(out[20:], uint32(compressed.Len()))
+	binary.BigEndian.PutUint16(out[24:], 1) // majorVersion
+	// minorVersion, metadata and private data are left empty
+
+	out = append(out, directory...)
+	out = append(out, compressed.Bytes()...)
+	return append(out, make([]byte, length-len(out))...), nil
+}
+
+// appendUIntBase128 appends a WOFF2 variable-length integer
+func appendUIntBase128(b []byte, v uint32) []byte {
+	var digits []byte
+	for {
+		digits = append([]byte{byte(v & 0x7F)}, digits...)
+		v >>= 7
+		if v == 0 {
+			break
+		}
+	}
+	for i := 0; i < len(digits)-1; i++ {
+		digits[i] |= 0x80
+	}
+	return append(b, digits...)
+}
@@ -0,0 +1,402 @@
+package freezelib
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/landaiqing/freezelib/font"
+	"golang.org/x/image/font/sfnt"
+)
+
+func TestFontSubset(t *testing.T) {
+	subset, err := font.Subset(font.JetBrainsMonoTTF, "x -> y")
+	if err != nil {
+		t.Fatalf("Subset failed: %v", err)
+	}
+	if len(subset) >= len(font.JetBrainsMonoTTF)/2 {
+		t.Errorf("Subset() size = %d, want less than half of %d", len(subset), len(font.JetBrainsMonoTTF))
+	}
+
+	f, err := sfnt.Parse(subset)
+	if err != nil {
+		t.Fatalf("subset font does not parse: %v", err)
+	}
+	var buf sfnt.Buffer
+	for _, tt := range []struct {
+		r    rune
+		kept bool
+	}{{'x', true}, {'>', true}, {'Q', false}} {
+		idx, err := f.GlyphIndex(&buf, tt.r)
+		if err != nil {
+			t.Fatalf("GlyphIndex(%q) failed: %v", tt.r, err)
+		}
+		if (idx != 0) != tt.kept {
+			t.Errorf("GlyphIndex(%q) = %d, kept should be %v", tt.r, idx, tt.kept)
+		}
+	}
+}
+
+func TestEmbedFontFile(t *testing.T) {
+	fontFile := filepath.Join(t.TempDir(), "JetBrainsMono.ttf")
+	if err := os.WriteFile(fontFile, font.JetBrainsMonoTTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		subset  bool
+		woff2   bool
+		format  string
+		maxSize int
+	}{
+		{"Full font", false, false, "format('truetype')", 400000},
+		{"Subset", true, false, "format('truetype')", 120000},
+		{"Subset WOFF2", true, true, "format('woff2')", 50000},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Font.File = fontFile
+			config.Font.Subset = tt.subset
+			config.Font.WOFF2 = tt.woff2
+
+			svgData, err := NewGenerator(config).GenerateFromCode("fmt.Println(\"hello\")", "go")
+			if err != nil {
+				t.Fatalf("GenerateFromCode failed: %v", err)
+			}
+			if !strings.Contains(string(svgData), tt.format) {
+				t.Errorf("SVG should embed the font with %s", tt.format)
+			}
+			if len(svgData) > tt.maxSize {
+				t.Errorf("SVG size = %d, want at most %d", len(svgData), tt.maxSize)
+			}
+		})
+	}
+}
+
+func TestFontVariants(t *testing.T) {
+	dir := t.TempDir()
+	regular := filepath.Join(dir, "Regular.ttf")
+	bold := filepath.Join(dir, "Bold.ttf")
+	for _, file := range []string{regular, bold} {
+		if err := os.WriteFile(file, font.JetBrainsMonoTTF, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	config := DefaultConfig()
+	config.Font.File = regular
+	config.SetFontVariants(bold, "", "")
+
+	svgData, err := NewGenerator(config).GenerateFromANSI("\x1b[1mbold\x1b[0m")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+	for _, expected := range []string{
+		"font-weight: normal; font-style: normal;",
+		"font-weight: bold; font-style: normal;",
+		`font-weight="bold"`,
+	} {
+		if !strings.Contains(string(svgData), expected) {
+			t.Errorf("SVG should contain %s", expected)
+		}
+	}
+}
+
+func TestBundledFontVariants(t *testing.T) {
+	tests := []struct {
+		name    string
+		variant font.Variant
+		sgr     string
+	}{
+		{"Bold", font.Bold, "1"},
+		{"Italic", font.Italic, "3"},
+		{"Bold italic", font.BoldItalic, "1;3"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, ok := font.Lookup("JetBrains Mono", tt.variant); !ok {
+				t.Skipf("JetBrains Mono %s is not bundled", tt.variant)
+			}
+
+			g := NewGenerator(DefaultConfig())
+			svgData, err := g.GenerateFromANSI("\x1b[" + tt.sgr + "mtext")
+			if err != nil {
+				t.Fatalf("GenerateFromANSI failed: %v", err)
+			}
+			fontFace := fmt.Sprintf("font-weight: %s; font-style: %s;", tt.variant.Weight(), tt.variant.Style())
+			if !strings.Contains(string(svgData), fontFace) {
+				t.Errorf("SVG should embed the %s face", tt.variant)
+			}
+
+			// The rasterizer does not synthesize variants, so the text
+			// only renders differently with the real face
+			styled, err := g.ConvertToPNG(svgData, 200, 100)
+			if err != nil {
+				t.Fatalf("ConvertToPNG failed: %v", err)
+			}
+			svgData, err = g.GenerateFromANSI("text")
+			if err != nil {
+				t.Fatalf("GenerateFromANSI failed: %v", err)
+			}
+			regular, err := g.ConvertToPNG(svgData, 200, 100)
+			if err != nil {
+				t.Fatalf("ConvertToPNG failed: %v", err)
+			}
+			if bytes.Equal(styled, regular) {
+				t.Errorf("PNG should render the %s face", tt.variant)
+			}
+		})
+	}
+}
+
+func TestLigatures(t *testing.T) {
+	tests := []struct {
+		name      string
+		ligatures bool
+		expected  []string
+	}{
+		{"Enabled", true, []string{`font-variant-ligatures="normal"`, "liga&quot; 1"}},
+		{"Disabled", false, []string{`font-variant-ligatures="none"`, "liga&quot; 0"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Font.Ligatures = tt.ligatures
+			svgData, err := NewGenerator(config).GenerateFromCode("a -> b", "go")
+			if err != nil {
+				t.Fatalf("GenerateFromCode failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(svgData), expected) {
+					t.Errorf("SVG should contain %s", expected)
+				}
+			}
+		})
+	}
+}
+
+func TestEmbedBundledFont(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   func() *Config
+		expected bool
+	}{
+		{"Default", DefaultConfig, true},
+		{"Disabled", func() *Config { return DefaultConfig().SetFontEmbedding(false, true, true) }, false},
+		{"Missing family", func() *Config { return DefaultConfig().SetFont("Missing Mono", 14) }, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svgData, err := NewGenerator(tt.config()).GenerateFromCode("package main", "go")
+			if err != nil {
+				t.Fatalf("GenerateFromCode failed: %v", err)
+			}
+			embedded := strings.Contains(string(svgData), "font-family: 'JetBrains Mono'; src: url(data:font/woff2;base64,")
+			if embedded != tt.expected {
+				t.Errorf("font embedded = %v, want %v", embedded, tt.expected)
+			}
+		})
+	}
+}
+
+func TestFontRegistry(t *testing.T) {
+	fontFile := filepath.Join(t.TempDir(), "Registered.ttf")
+	if err := os.WriteFile(fontFile, font.JetBrainsMonoNLTTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := font.RegisterFile("Registered Mono", font.Regular, fontFile); err != nil {
+		t.Fatalf("RegisterFile failed: %v", err)
+	}
+	t.Cleanup(func() { font.Unregister("Registered Mono") })
+
+	for _, tt := range []struct {
+		family    string
+		monospace bool
+	}{{"Registered Mono", true}, {"JetBrains Mono", true}, {"monospace", true}, {"Unknown Sans", false}} {
+		if got := font.IsMonospaceFont(tt.family); got != tt.monospace {
+			t.Errorf("IsMonospaceFont(%q) = %v, want %v", tt.family, got, tt.monospace)
+		}
+	}
+
+	config := DefaultConfig().SetFont("Registered Mono", 14)
+	svgData, err := NewGenerator(config).GenerateFromCode("a -> b", "go")
+	if err != nil {
+		t.Fatalf("GenerateFromCode failed: %v", err)
+	}
+	if !strings.Contains(string(svgData), "font-family: 'Registered Mono'; src: url(data:") {
+		t.Errorf("SVG should embed the registered font")
+	}
+	if _, err := NewGenerator(config).ConvertToPNG(svgData, 200, 100); err != nil {
+		t.Errorf("ConvertToPNG failed: %v", err)
+	}
+
+	// Only the bundled font has a variant without ligatures for PNG output
+	config.Font.Ligatures = false
+	g := NewGenerator(config)
+	if svgData, err = g.GenerateFromCode("a -> b", "go"); err != nil {
+		t.Fatalf("GenerateFromCode failed: %v", err)
+	}
+	if _, err := g.ConvertToPNG(svgData, 200, 100); err != nil {
+		t.Errorf("ConvertToPNG failed: %v", err)
+	}
+	if warnings := g.LastResult().Warnings; len(warnings) != 1 || !strings.Contains(warnings[0], "ligatures cannot be disabled for Registered Mono") {
+		t.Errorf("LastResult().Warnings = %q, want a ligatures warning", warnings)
+	}
+}
+
+func TestSystemFonts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "truetype")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	fontFile := filepath.Join(dir, "JetBrainsMonoNL-Regular.ttf")
+	if err := os.WriteFile(fontFile, font.JetBrainsMonoNLTTF, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	found := font.FindSystemFont("JetBrains Mono NL", filepath.Dir(dir))
+	if len(found) != 1 || found[0].Path != fontFile || found[0].Variant != font.Regular {
+		t.Errorf("FindSystemFont() = %v, want %s", found, fontFile)
+	}
+
+	// Installed fonts are used without being registered
+	font.Unregister("JetBrains Mono NL")
+	t.Cleanup(func() { _ = font.Register("JetBrains Mono NL", font.Regular, font.JetBrainsMonoNLTTF) })
+	g := NewGenerator(DefaultConfig().SetFont("JetBrains Mono NL", 14).SetFontDirs(filepath.Dir(dir)))
+	svgData, err := g.GenerateFromCode("a -> b", "go")
+	if err != nil {
+		t.Fatalf("GenerateFromCode failed: %v", err)
+	}
+	if result := g.LastResult(); result.Font.Source != font.SourceSystem || result.Font.Path != fontFile {
+		t.Errorf("LastResult().Font = %+v, want system font %s", result.Font, fontFile)
+	}
+	if strings.Contains(string(svgData), "font-family: 'JetBrains Mono NL'; src: url(data:") {
+		t.Errorf("SVG should not embed the installed font by default")
+	}
+	if _, err := g.ConvertToPNG(svgData, 200, 100); err != nil {
+		t.Errorf("ConvertToPNG failed: %v", err)
+	}
+	g.config.SetSystemFontEmbedding(true)
+	svgData, err = g.GenerateFromCode("a -> b", "go")
+	if err != nil {
+		t.Fatalf("GenerateFromCode failed: %v", err)
+	}
+	if !strings.Contains(string(svgData), "font-family: 'JetBrains Mono NL'; src: url(data:") {
+		t.Errorf("SVG should embed the installed font when enabled")
+	}
+	if font.IsRegistered("JetBrains Mono NL") {
+		t.Error("Installed fonts should not be registered")
+	}
+	if !font.IsMonospaceFont("JetBrains Mono NL", filepath.Dir(dir)) {
+		t.Errorf("IsMonospaceFont() should check installed fonts")
+	}
+	fallbacks, err := font.LoadFallbacks([]string{"JetBrains Mono NL"}, filepath.Dir(dir))
+	if err != nil {
+		t.Fatalf("LoadFallbacks failed: %v", err)
+	}
+	if len(fallbacks) != 1 || len(fallbacks[0].Data) == 0 {
+		t.Errorf("LoadFallbacks() should load installed fonts by family name")
+	}
+
+	tests := []struct {
+		name    string
+		family  string
+		strict  bool
+		source  font.Source
+		used    string
+		wantErr bool
+	}{
+		{"Registered", "JetBrains Mono", false, font.SourceRegistered, "JetBrains Mono", false},
+		{"Missing", "Missing Mono", false, font.SourceDefault, "JetBrains Mono", false},
+		{"Missing strict", "Missing Mono", true, font.SourceDefault, "JetBrains Mono", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			freeze := NewWithConfig(DefaultConfig().SetFont(tt.family, 14).SetFontStrict(tt.strict))
+			svgData, err := freeze.GenerateFromCode("a -> b", "go")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GenerateFromCode() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			result := freeze.LastResult()
+			if result.Font.Source != tt.source || result.Font.Family != tt.used {
+				t.Errorf("LastResult().Font = %+v, want %s font %s", result.Font, tt.source, tt.used)
+			}
+			if tt.source == font.SourceDefault && !tt.strict {
+				if len(result.Warnings) == 0 {
+					t.Errorf("LastResult().Warnings should report the missing family")
+				}
+				if !strings.Contains(string(svgData), `font-family="&apos;Missing Mono&apos;, &apos;JetBrains Mono&apos;, monospace"`) {
+					t.Errorf("SVG should fall back to the default font")
+				}
+			}
+		})
+	}
+}
+
+// fontCollection builds TTC collection data from TTF fonts
+func fontCollection(fonts ...[]byte) []byte {
+	header := 12 + 4*len(fonts)
+	data := make([]byte, header)
+	copy(data, "ttcf")
+	binary.BigEndian.PutUint32(data[4:], 0x00010000)
+	binary.BigEndian.PutUint32(data[8:], uint32(len(fonts)))
+	for i, f := range fonts {
+		base := len(data)
+		binary.BigEndian.PutUint32(data[12+4*i:], uint32(base))
+		f = append([]byte(nil), f...)
+		for j := 0; j < int(binary.BigEndian.Uint16(f[4:])); j++ {
+			record := f[12+16*j+8:]
+			binary.BigEndian.PutUint32(record, binary.BigEndian.Uint32(record)+uint32(base))
+		}
+		data = append(data, f...)
+	}
+	return data
+}
+
+func TestFontCollections(t *testing.T) {
+	dir := t.TempDir()
+	fontFile := filepath.Join(dir, "JetBrainsMono.ttc")
+	if err := os.WriteFile(fontFile, fontCollection(font.JetBrainsMonoTTF, font.JetBrainsMonoNLTTF), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	found := font.FindSystemFont("JetBrains Mono NL", dir)
+	if len(found) != 1 || found[0].Index != 1 {
+		t.Fatalf("FindSystemFont() = %v, want the second font of %s", found, fontFile)
+	}
+
+	// Only the matching face is loaded and embedded
+	font.Unregister("JetBrains Mono NL")
+	t.Cleanup(func() { _ = font.Register("JetBrains Mono NL", font.Regular, font.JetBrainsMonoNLTTF) })
+	resolution, err := font.Resolve("JetBrains Mono NL", dir)
+	if err != nil {
+		t.Fatalf("Resolve failed: %v", err)
+	}
+	f, ok := resolution.Font(font.Regular)
+	if !ok || font.IsCollection(f.Data) {
+		t.Fatalf("Resolve() should load the font out of the collection")
+	}
+	if family, err := font.FamilyName(f.Data); err != nil || family != "JetBrains Mono NL" {
+		t.Errorf("FamilyName() = %q, %v, want JetBrains Mono NL", family, err)
+	}
+
+	fontFace, err := font.FontFace("JetBrains Mono", fontCollection(font.JetBrainsMonoTTF), font.EmbedOptions{})
+	if err != nil {
+		t.Fatalf("FontFace failed: %v", err)
+	}
+	if strings.Contains(fontFace, "base64,"+base64.StdEncoding.EncodeToString([]byte("ttcf"))[:4]) {
+		t.Errorf("FontFace() should embed the font out of the collection")
+	}
+}
@@ -0,0 +1,200 @@
+package freezelib
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/kanrichan/resvg-go"
+	"github.com/landaiqing/freezelib/font"
+	"github.com/landaiqing/freezelib/svg"
+)
+
+// fontFile is a configured font file and the variant it provides
+type fontFile struct {
+	path    string
+	variant font.Variant
+}
+
+// files returns the configured font files
+func (f Font) files() []fontFile {
+	var files []fontFile
+	for _, file := range []fontFile{
+		{f.File, font.Regular},
+		{f.Bold, font.Bold},
+		{f.Italic, font.Italic},
+		{f.BoldItalic, font.BoldItalic},
+	} {
+		if file.path != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// fontSource is font data to embed into the SVG
+type fontSource struct {
+	data    []byte
+	variant font.Variant
+}
+
+// resolveFont finds the configured font family and records the font used
+// in the render result. A missing family falls back to the default font
+// with a warning unless the font configuration is strict.
+func (g *Generator) resolveFont() error {
+	family := g.config.Font.Family
+	g.result = RenderResult{}
+
+	if g.config.Font.File != "" {
+		g.result.Font = font.Resolution{
+			Requested: family,
+			Family:    family,
+			Source:    font.SourceFile,
+			Path:      g.config.Font.File,
+		}
+		return nil
+	}
+
+	resolution, err := font.Resolve(family, g.config.Font.Dirs...)
+	g.result.Font = resolution
+	if errors.Is(err, font.ErrFamilyNotFound) && !g.config.Font.Strict {
+		g.result.Warnings = append(g.result.Warnings, fmt.Sprintf("%v, using %s", err, resolution.Family))
+		return nil
+	}
+	return err
+}
+
+// embedFonts embeds the configured font files into the SVG, or when there
+// are none and embedding is enabled, the fonts of the resolved family.
+// Installed fonts are only embedded when enabled as well. The fonts are
+// limited to the glyphs used by text when subsetting is enabled.
+func (g *Generator) embedFonts(image *etree.Element, text string) error {
+	config := g.config
+
+	var sources []fontSource
+	for _, file := range config.Font.files() {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return fmt.Errorf("failed to read font file: %w", err)
+		}
+		sources = append(sources, fontSource{data, file.variant})
+	}
+	system := g.result.Font.Source == font.SourceSystem
+	if len(sources) == 0 && config.Font.Embed && (!system || config.Font.EmbedSystem) {
+		for _, variant := range font.Variants {
+			if registered, ok := g.result.Font.Font(variant); ok {
+				sources = append(sources, fontSource{registered.Data, variant})
+			}
+		}
+	}
+
+	for _, source := range sources {
+		fontFace, err := font.FontFace(g.result.Font.Family, source.data, font.EmbedOptions{
+			Text:    text,
+			Subset:  config.Font.Subset,
+			WOFF2:   config.Font.WOFF2,
+			Variant: source.variant,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to embed font file: %w", err)
+		}
+		svg.AddStyleSheet(image, fontFace)
+	}
+	return nil
+}
+
+// loadFonts loads the fonts of the configured family, the default family,
+// the configured font files and the fallback fonts into the rasterizer font
+// database
+func (g *Generator) loadFonts(fontdb *resvg.FontDB) error {
+	resolution, err := font.Resolve(g.config.Font.Family, g.config.Font.Dirs...)
+	if err != nil && !errors.Is(err, font.ErrFamilyNotFound) {
+		return err
+	}
+	fonts := resolution.Fonts()
+
+	// The default family is the last resort of the font stack, and the
+	// rasterizer needs the families without ligatures to disable them
+	families := []string{font.GetDefaultFontFamily()}
+	if !g.config.Font.Ligatures {
+		for _, family := range []string{resolution.Family, font.GetDefaultFontFamily()} {
+			if noLigatures, ok := font.NoLigaturesFamily(family); ok {
+				families = append(families, noLigatures)
+			}
+		}
+	}
+	loaded := map[string]bool{strings.ToLower(resolution.Family): true}
+	for _, family := range families {
+		if loaded[strings.ToLower(family)] {
+			continue
+		}
+		loaded[strings.ToLower(family)] = true
+		for _, variant := range font.Variants {
+			if registered, ok := font.Lookup(family, variant); ok {
+				fonts = append(fonts, registered)
+			}
+		}
+	}
+
+	for _, f := range fonts {
+		if err := fontdb.LoadFontData(f.Data); err != nil {
+			return fmt.Errorf("could not load %s %s font: %w", f.Family, f.Variant, err)
+		}
+	}
+
+	// Load configured font files
+	for _, file := range g.config.Font.files() {
+		data, err := os.ReadFile(file.path)
+		if err != nil {
+			return fmt.Errorf("failed to read font file: %w", err)
+		}
+		if _, err := font.FamilyName(data); err != nil {
+			// Web fonts such as WOFF2 are only usable in SVG output
+			continue
+		}
+		if err := fontdb.LoadFontData(data); err != nil {
+			return fmt.Errorf("could not load font file %s: %w", file.path, err)
+		}
+	}
+
+	// Load fallback fonts
+	fallbacks, err := font.LoadFallbacks(g.config.Font.Fallbacks, g.config.Font.Dirs...)
+	if err != nil {
+		return err
+	}
+	for _, fallback := range fallbacks {
+		if len(fallback.Data) == 0 {
+			continue
+		}
+		if err := fontdb.LoadFontData(fallback.Data); err != nil {
+			return fmt.Errorf("could not load fallback font %s: %w", fallback.Family, err)
+		}
+	}
+	return nil
+}
+
+// useNoLigaturesFonts replaces the font families of the element and its
+// descendants with their embedded variants without ligatures. It reports
+// whether any family was replaced.
+func useNoLigaturesFonts(element *etree.Element) bool {
+	replaced := false
+	if attr := element.SelectAttr("font-family"); attr != nil {
+		families := strings.Split(attr.Value, ",")
+		for i, family := range families {
+			name := strings.Trim(strings.TrimSpace(family), `'"`)
+			if noLigatures, ok := font.NoLigaturesFamily(name); ok {
+				families[i] = fmt.Sprintf("'%s'", noLigatures)
+				replaced = true
+			}
+		}
+		attr.Value = strings.Join(families, ",")
+	}
+	for _, child := range element.ChildElements() {
+		if useNoLigaturesFonts(child) {
+			replaced = true
+		}
+	}
+	return replaced
+}
@@ -7,10 +7,13 @@
 package freezelib
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"sort"
+	"time"
 
 	"github.com/alecthomas/chroma/v2/lexers"
 	"github.com/alecthomas/chroma/v2/styles"
@@ -51,6 +54,12 @@ func NewWithPreset(presetName string) *Freeze {
 	}
 }
 
+// LastResult returns how the last screenshot was rendered, including the
+// font actually used
+func (f *Freeze) LastResult() RenderResult {
+	return f.generator.LastResult()
+}
+
 // Config returns the current configuration
 func (f *Freeze) Config() *Config {
 	return f.config
@@ -99,6 +108,30 @@ func (f *Freeze) GenerateFromANSI(ansiOutput string) ([]byte, error) {
 	return f.generator.GenerateFromANSI(ansiOutput)
 }
 
+// GenerateFromCast generates an SVG screenshot of an asciinema recording at
+// the given time, or at its end when at is 0
+func (f *Freeze) GenerateFromCast(r io.Reader, at time.Duration) ([]byte, error) {
+	return f.generator.GenerateFromCast(r, at)
+}
+
+// GenerateFromCommand runs a command in a pseudo-terminal and generates an
+// SVG screenshot of its output below a prompt line showing the command
+func (f *Freeze) GenerateFromCommand(ctx context.Context, cmd *exec.Cmd, opts CaptureOptions) ([]byte, error) {
+	return f.generator.GenerateFromCommand(ctx, cmd, opts)
+}
+
+// GenerateAnimation generates an animation in the given format (AnimatedSVG,
+// AnimatedGIF or AnimatedPNG) from timed chunks of ANSI output
+func (f *Freeze) GenerateAnimation(chunks []Chunk, format string) ([]byte, error) {
+	return f.generator.GenerateAnimation(chunks, format)
+}
+
+// GenerateAnimationFromCast generates an animation in the given format from
+// an asciinema recording
+func (f *Freeze) GenerateAnimationFromCast(r io.Reader, format string) ([]byte, error) {
+	return f.generator.GenerateAnimationFromCast(r, format)
+}
+
 // GeneratePNGFromCode generates a PNG screenshot from source code
 func (f *Freeze) GeneratePNGFromCode(code, language string) ([]byte, error) {
 	svgData, err := f.generator.GenerateFromCode(code, language)
@@ -391,6 +424,7 @@ func (f *Freeze) GetSupportedThemes() []string {
 	for name := range styles.Registry {
 		themes = append(themes, name)
 	}
+	themes = append(themes, customThemeNames()...)
 	sort.Strings(themes)
 	return themes
 }
@@ -427,6 +461,7 @@ func GetSupportedThemes() []string {
 	for name := range styles.Registry {
 		themes = append(themes, name)
 	}
+	themes = append(themes, customThemeNames()...)
 	sort.Strings(themes)
 	return themes
 }
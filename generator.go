@@ -7,16 +7,18 @@ import (
 	"fmt"
 	"github.com/landaiqing/freezelib/font"
 	"github.com/landaiqing/freezelib/svg"
+	"io"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/chroma/v2"
 	formatter "github.com/alecthomas/chroma/v2/formatters/svg"
-	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/beevik/etree"
 	"github.com/charmbracelet/lipgloss"
 	"github.com/charmbracelet/x/ansi"
-	"github.com/charmbracelet/x/cellbuf"
 	"github.com/kanrichan/resvg-go"
 )
 
@@ -29,6 +31,29 @@ const (
 type Generator struct {
 	config           *Config
 	languageDetector *LanguageDetector
+	result           RenderResult
+}
+
+// renderOptions are options of a single rendering that are not part of the
+// configuration, such as those of the frames of an animation
+type renderOptions struct {
+	// palette replaces the configured terminal palette when set
+	palette *TerminalPalette
+	// glyphs is text embedded fonts must cover besides the rendered text,
+	// such as the text of the other frames of an animation
+	glyphs string
+	// skipFonts skips embedding fonts, for the frames of an animation after
+	// the first and frames rendered to images
+	skipFonts bool
+}
+
+// RenderResult describes how the last screenshot was rendered
+type RenderResult struct {
+	// Font is the font used for the configured font family
+	Font font.Resolution
+	// Warnings are problems that did not prevent rendering, such as a
+	// missing font family
+	Warnings []string
 }
 
 // NewGenerator creates a new generator with the given configuration
@@ -42,6 +67,18 @@ func NewGenerator(config *Config) *Generator {
 	}
 }
 
+// LastResult returns how the last screenshot was rendered
+func (g *Generator) LastResult() RenderResult {
+	return g.result
+}
+
+// warn adds a warning to the render result unless it is already there
+func (g *Generator) warn(warning string) {
+	if !slices.Contains(g.result.Warnings, warning) {
+		g.result.Warnings = append(g.result.Warnings, warning)
+	}
+}
+
 // GenerateFromCode generates an SVG from source code
 func (g *Generator) GenerateFromCode(code, language string) ([]byte, error) {
 	if err := g.config.Validate(); err != nil {
@@ -59,7 +96,7 @@ func (g *Generator) GenerateFromCode(code, language string) ([]byte, error) {
 		return nil, errors.New("could not determine language for syntax highlighting")
 	}
 
-	return g.generateSVG(code, lexer, false)
+	return g.generateSVG(code, lexer)
 }
 
 // GenerateFromFile generates an SVG from a source code file
@@ -82,7 +119,7 @@ func (g *Generator) GenerateFromFile(filename string) ([]byte, error) {
 		return nil, errors.New("could not determine language for syntax highlighting")
 	}
 
-	return g.generateSVG(code, lexer, false)
+	return g.generateSVG(code, lexer)
 }
 
 // DetectLanguage detects the programming language from code content
@@ -136,34 +173,92 @@ func (g *Generator) GenerateFromANSI(ansiOutput string) ([]byte, error) {
 		return nil, fmt.Errorf("invalid config: %w", err)
 	}
 
-	// For ANSI output, we use a text lexer but handle ANSI sequences specially
-	strippedInput := ansi.Strip(ansiOutput)
-	it := chroma.Literator(chroma.Token{Type: chroma.Text, Value: strippedInput})
+	// Replay cursor movement and line editing so only the final screen
+	// contents are rendered
+	ansiOutput = g.config.Prompt.prepend(g.config.Prompt.Commands, ansiOutput, g.config.Terminal.Columns)
+	return g.generateFromScreen(emulate(ansiOutput, g.config.Terminal), renderOptions{})
+}
+
+// GenerateFromCast generates an SVG from an asciinema recording (asciicast
+// v2), showing the terminal screen at the given time or at the end of the
+// recording when at is 0. The recording's terminal size is used, as well
+// as its theme unless a terminal palette is configured.
+func (g *Generator) GenerateFromCast(r io.Reader, at time.Duration) ([]byte, error) {
+	if err := g.config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config: %w", err)
+	}
+
+	cast, err := ParseCast(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return g.generateFromScreen(cast.screen(at), g.castOptions(cast.Header))
+}
+
+// castOptions returns the options rendering a recording with the palette
+// of its theme unless a terminal palette is configured
+func (g *Generator) castOptions(header CastHeader) renderOptions {
+	var opts renderOptions
+	if tp, ok := header.palette(); ok && g.config.TerminalPalette == (TerminalPalette{}) {
+		opts.palette = &tp
+	}
+	return opts
+}
+
+// generateFromScreen generates an SVG from the contents of a terminal screen
+func (g *Generator) generateFromScreen(s *screen, opts renderOptions) ([]byte, error) {
+	src, err := g.prepareInput(s.String())
+	if err != nil {
+		return nil, err
+	}
+	if g.config.Wrap > 0 {
+		src = src.wrap(g.config.Wrap)
+	}
+
+	// Interpret the selected lines into the cell grid the text is drawn
+	// from. The formatter only lays out the lines; as it takes a trailing
+	// newline as the end of the last line, one is added to keep the empty
+	// last line of a screen with a fixed number of rows.
+	grid := interpret(src.text)
+	grid.columns = s.columns
+	grid.cursorHidden = s.cursorHidden
+	placeCursor(grid, src, s.row, s.col)
+	lines := ansi.Strip(src.text)
+	if s.rows > 0 {
+		lines += "\n"
+	}
+	it := chroma.Literator(chroma.Token{Type: chroma.Text, Value: lines})
 
-	return g.generateSVGFromIterator(ansiOutput, it, true)
+	return g.generateSVGFromIterator(src, it, grid, opts)
 }
 
 // generateSVG is the core SVG generation function
-func (g *Generator) generateSVG(input string, lexer chroma.Lexer, isAnsi bool) ([]byte, error) {
-	// Create token iterator
-	var it chroma.Iterator
-	var err error
-	if isAnsi {
-		strippedInput := ansi.Strip(input)
-		it = chroma.Literator(chroma.Token{Type: chroma.Text, Value: strippedInput})
-	} else {
-		it, err = chroma.Coalesce(lexer).Tokenise(nil, input)
-		if err != nil {
-			return nil, fmt.Errorf("could not tokenize input: %w", err)
-		}
+func (g *Generator) generateSVG(input string, lexer chroma.Lexer) ([]byte, error) {
+	// Apply line selection, normalization, redaction and wrapping before
+	// tokenizing
+	src, err := g.prepareInput(input)
+	if err != nil {
+		return nil, err
+	}
+	if g.config.Wrap > 0 {
+		src = src.wrap(g.config.Wrap)
 	}
 
-	return g.generateSVGFromIterator(input, it, isAnsi)
+	it, err := chroma.Coalesce(lexer).Tokenise(nil, src.text)
+	if err != nil {
+		return nil, fmt.Errorf("could not tokenize input: %w", err)
+	}
+
+	return g.generateSVGFromIterator(src, it, nil, renderOptions{})
 }
 
-// generateSVGFromIterator generates SVG from a token iterator
-func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, isAnsi bool) ([]byte, error) {
+// generateSVGFromIterator generates SVG from a token iterator over the
+// prepared source. For ANSI output the text is drawn from the cell grid of
+// the source instead of the tokens.
+func (g *Generator) generateSVGFromIterator(src source, it chroma.Iterator, grid *screen, opts renderOptions) ([]byte, error) {
 	config := g.config
+	isAnsi := grid != nil
 
 	// Calculate scale factor
 	scale := 1.0
@@ -174,40 +269,30 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 	expandedMargin := config.expandMargin(scale)
 	expandedPadding := config.expandPadding(scale)
 
-	// Process input based on line selection
-	processedInput := input
-	if len(config.Lines) == 2 {
-		processedInput = cutLines(input, config.Lines)
-	}
-
-	// Handle text wrapping
-	if config.Wrap > 0 {
-		processedInput = cellbuf.Wrap(processedInput, config.Wrap, "")
+	// Get style with background and theme overrides applied
+	style, err := config.resolveStyle()
+	if err != nil {
+		return nil, err
 	}
 
-	// Get style
-	style, ok := styles.Registry[strings.ToLower(config.Theme)]
-	if !ok || style == nil {
-		style = styles.Get("github") // fallback to github style
+	// Resolve the font family
+	if err := g.resolveFont(); err != nil {
+		return nil, err
 	}
 
-	// Add background color to style if not present
-	if !style.Has(chroma.Background) {
-		var err error
-		style, err = style.Builder().Add(chroma.Background, "bg:"+config.Background).Build()
-		if err != nil {
-			return nil, fmt.Errorf("could not add background: %w", err)
-		}
-	}
+	// Get font metrics for layout
+	metrics := g.fontMetrics()
+	cellWidth := metrics.CellWidth(config.Font.Size) * scale
 
-	// Get font options
-	fontOptions, err := font.FontOptions(config.Font.Family, config.Font.Size, config.Font.Ligatures, config.Font.File)
+	// Load fallback fonts
+	fallbacks, err := font.LoadFallbacks(config.Font.Fallbacks, config.Font.Dirs...)
 	if err != nil {
-		return nil, fmt.Errorf("invalid font options: %w", err)
+		return nil, err
 	}
 
-	// Create SVG formatter
-	f := formatter.New(fontOptions...)
+	// Create SVG formatter. Fonts are embedded below, limited to the
+	// rendered glyphs.
+	f := formatter.New(formatter.FontFamily(config.Font.Family))
 
 	// Format to SVG
 	buf := &bytes.Buffer{}
@@ -230,6 +315,17 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 
 	image := elements[0]
 
+	// Embed the font files
+	if !opts.skipFonts {
+		renderedText := ansi.Strip(src.text) + opts.glyphs
+		if config.ShowLineNumbers {
+			renderedText += "0123456789"
+		}
+		if err := g.embedFonts(image, renderedText); err != nil {
+			return nil, err
+		}
+	}
+
 	// Calculate dimensions
 	w, h := svg.GetDimensions(image)
 	imageWidth := float64(w) * scale
@@ -239,6 +335,15 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 	imageHeight *= config.Font.Size / defaultFontSize
 	imageHeight *= config.LineHeight / defaultLineHeight
 
+	// Size ANSI output to its rows of cells. The first baseline is one line
+	// height below the top, so the cells start below it by the leading
+	// above the baseline.
+	if isAnsi {
+		lineHeightPx := config.Font.Size * config.LineHeight * scale
+		lines := len(image.FindElements("./g/text"))
+		imageHeight = float64(lines+1)*lineHeightPx - metrics.BaselineOffset(config.Font.Size*scale, lineHeightPx)
+	}
+
 	terminalWidth := imageWidth
 	terminalHeight := imageHeight
 
@@ -270,12 +375,27 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 		return nil, errors.New("could not find terminal background element")
 	}
 
+	// Use the terminal palette's default background for ANSI output
+	terminalPalette := config.TerminalPalette
+	if opts.palette != nil {
+		terminalPalette = *opts.palette
+	}
+	terminalPalette = terminalPalette.withDefaults(style, config.Background)
+	if isAnsi {
+		terminal.CreateAttr("fill", terminalPalette.Background)
+	}
+
 	// Add window controls if enabled
 	if config.Window {
 		windowControls := svg.NewWindowControls(5.5*scale, 19.0*scale, 12.0*scale)
 		svg.Move(windowControls, expandedMargin[left], expandedMargin[top])
 		image.AddChild(windowControls)
 		expandedPadding[top] += 15 * scale
+		// ANSI output is sized to its rows, so make room for the controls
+		if isAnsi && autoHeight {
+			imageHeight += 15 * scale
+			terminalHeight += 15 * scale
+		}
 	}
 
 	// Add corner radius
@@ -294,15 +414,36 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 	textGroup := image.SelectElement("g")
 	if textGroup != nil {
 		textGroup.CreateAttr("font-size", fmt.Sprintf("%.2fpx", config.Font.Size*scale))
+		families := []string{config.Font.Family}
+		for _, fallback := range fallbacks {
+			families = append(families, fallback.Family)
+		}
+		if g.result.Font.Family != config.Font.Family {
+			families = append(families, g.result.Font.Family)
+		}
+		if len(families) > 1 {
+			textGroup.CreateAttr("font-family", font.FamilyStack(families...))
+		}
+		if config.Font.Ligatures {
+			textGroup.CreateAttr("font-variant-ligatures", "normal")
+		} else {
+			textGroup.CreateAttr("font-variant-ligatures", "none")
+		}
+		svg.AddStyle(textGroup, "font-feature-settings: "+font.LigatureFeatures(config.Font.Ligatures))
 		textGroup.CreateAttr("clip-path", "url(#terminalMask)")
 		text := textGroup.SelectElements("text")
 
-		offsetLine := 0
-		if len(config.Lines) > 0 {
-			offsetLine = config.Lines[0]
-		}
-
 		lineHeight := config.LineHeight * scale
+		lineHeightPx := config.Font.Size * lineHeight
+		textX := expandedPadding[left] + expandedMargin[left]
+		textY := expandedPadding[top] + expandedMargin[top]
+
+		// Terminal cells start after the line number prefix
+		cellsX := textX
+		if config.ShowLineNumbers {
+			cellsX += lineNumberCells * cellWidth
+		}
+		layout := newCellLayout(metrics, config.Font.Size*scale, lineHeightPx, cellsX, textY+lineHeightPx)
 
 		for i, line := range text {
 			if isAnsi {
@@ -314,15 +455,18 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 				ln := etree.NewElement("tspan")
 				ln.CreateAttr("xml:space", "preserve")
 				ln.CreateAttr("fill", style.Get(chroma.LineNumbers).Colour.String())
-				ln.SetText(fmt.Sprintf("%3d  ", i+1+offsetLine))
+				number := ""
+				if n := lineNumber(src.lineNumbers, i); n >= 0 {
+					number = strconv.Itoa(n + 1)
+				}
+				ln.SetText(fmt.Sprintf("%*s  ", lineNumberCells-2, number))
 				line.InsertChildAt(0, ln)
 			}
 
 			// Position the line
-			x := expandedPadding[left] + expandedMargin[left]
-			y := (float64(i+1))*(config.Font.Size*lineHeight) + expandedPadding[top] + expandedMargin[top]
+			y := float64(i+1)*lineHeightPx + textY
 
-			svg.Move(line, x, y)
+			svg.Move(line, textX, y)
 
 			// Remove lines that are outside the visible area
 			if y > imageHeight-expandedMargin[bottom]-expandedPadding[bottom] {
@@ -332,20 +476,29 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 
 		// Process ANSI sequences if needed
 		if isAnsi {
-			processANSI(processedInput, text, textGroup, config, scale)
+			textGroup.CreateAttr("fill", terminalPalette.Foreground)
+			renderANSI(grid, text, textGroup, terminalPalette, config.Hyperlinks, layout)
+			if config.Cursor.Shape != "" && !grid.cursorHidden {
+				drawCursor(textGroup, grid, config.Cursor, terminalPalette, layout)
+			}
+		}
+
+		// Hide redacted secrets behind boxes
+		if len(src.redactions) > 0 {
+			drawRedactions(image, textGroup, src.redactions, config, layout)
 		}
 	}
 
 	// Calculate auto width based on content
 	if autoWidth {
-		tabWidth := 4
+		var longestLine int
 		if isAnsi {
-			tabWidth = 6
+			longestLine = grid.width()
+		} else {
+			strippedInput := ansi.Strip(src.text)
+			longestLine = lipgloss.Width(strings.ReplaceAll(strippedInput, "\t", strings.Repeat(" ", 4)))
 		}
-		strippedInput := ansi.Strip(processedInput)
-		longestLine := lipgloss.Width(strings.ReplaceAll(strippedInput, "\t", strings.Repeat(" ", tabWidth)))
-		terminalWidth = float64(longestLine+1) * (config.Font.Size / font.GetFontHeightToWidthRatio())
-		terminalWidth *= scale
+		terminalWidth = float64(longestLine+1) * cellWidth
 		terminalWidth += hPadding
 		imageWidth = terminalWidth + hMargin
 	}
@@ -360,10 +513,10 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 	// Adjust for line numbers
 	if config.ShowLineNumbers {
 		if autoWidth {
-			terminalWidth += config.Font.Size * 3 * scale
-			imageWidth += config.Font.Size * 3 * scale
+			terminalWidth += lineNumberCells * cellWidth
+			imageWidth += lineNumberCells * cellWidth
 		} else {
-			terminalWidth -= config.Font.Size * 3
+			terminalWidth -= lineNumberCells * cellWidth
 		}
 	}
 
@@ -385,41 +538,81 @@ func (g *Generator) generateSVGFromIterator(input string, it chroma.Iterator, is
 
 // ConvertToPNG converts SVG data to PNG format
 func (g *Generator) ConvertToPNG(svgData []byte, width, height float64) ([]byte, error) {
-	// Parse SVG document
-	doc := etree.NewDocument()
-	err := doc.ReadFromBytes(svgData)
+	r, err := g.newRasterizer()
 	if err != nil {
-		return nil, fmt.Errorf("could not parse SVG: %w", err)
+		return nil, err
 	}
+	defer r.Close()
 
-	// Use resvg for conversion
+	return r.render(svgData, width, height, 1)
+}
+
+// rasterizer renders SVG data with a resvg worker and font database, which
+// are slow to create and so shared by the frames of an animation
+type rasterizer struct {
+	generator *Generator
+	worker    *resvg.Worker
+	fontdb    *resvg.FontDB
+}
+
+// newRasterizer creates a rasterizer with the generator's fonts loaded
+func (g *Generator) newRasterizer() (*rasterizer, error) {
 	worker, err := resvg.NewDefaultWorker(context.Background())
 	if err != nil {
 		return nil, fmt.Errorf("could not create resvg worker: %w", err)
 	}
-	defer worker.Close()
 
 	fontdb, err := worker.NewFontDBDefault()
 	if err != nil {
+		worker.Close()
 		return nil, fmt.Errorf("could not create font database: %w", err)
 	}
-	defer fontdb.Close()
 
-	// Load embedded fonts
-	if len(font.JetBrainsMonoTTF) > 0 {
-		err = fontdb.LoadFontData(font.JetBrainsMonoTTF)
-		if err != nil {
-			return nil, fmt.Errorf("could not load JetBrains Mono font: %w", err)
+	// Load fonts
+	r := &rasterizer{generator: g, worker: worker, fontdb: fontdb}
+	if err := g.loadFonts(fontdb); err != nil {
+		r.Close()
+		return nil, err
+	}
+	return r, nil
+}
+
+// Close releases the font database and worker
+func (r *rasterizer) Close() {
+	r.fontdb.Close()
+	r.worker.Close()
+}
+
+// render renders SVG data scaled by scale onto an image of the given size
+// and returns it in PNG format
+func (r *rasterizer) render(svgData []byte, width, height, scale float64) ([]byte, error) {
+	// Parse SVG document
+	doc := etree.NewDocument()
+	err := doc.ReadFromBytes(svgData)
+	if err != nil {
+		return nil, fmt.Errorf("could not parse SVG: %w", err)
+	}
+
+	// The rasterizer always applies ligatures, so switch to fonts without
+	// them. Only the bundled fonts have such a variant.
+	if !r.generator.config.Font.Ligatures {
+		if useNoLigaturesFonts(doc.Root()) {
+			svgData, err = doc.WriteToBytes()
+			if err != nil {
+				return nil, fmt.Errorf("could not write SVG: %w", err)
+			}
+		} else {
+			r.generator.warn(fmt.Sprintf("ligatures cannot be disabled for %s in PNG output", r.generator.result.Font.Family))
 		}
 	}
 
-	pixmap, err := worker.NewPixmap(uint32(width), uint32(height))
+	pixmap, err := r.worker.NewPixmap(uint32(width), uint32(height))
 	if err != nil {
 		return nil, fmt.Errorf("could not create pixmap: %w", err)
 	}
 	defer pixmap.Close()
 
-	tree, err := worker.NewTreeFromData(svgData, &resvg.Options{
+	tree, err := r.worker.NewTreeFromData(svgData, &resvg.Options{
 		Dpi:                192,
 		ShapeRenderingMode: resvg.ShapeRenderingModeGeometricPrecision,
 		TextRenderingMode:  resvg.TextRenderingModeOptimizeLegibility,
@@ -432,12 +625,16 @@ func (g *Generator) ConvertToPNG(svgData []byte, width, height float64) ([]byte,
 	}
 	defer tree.Close()
 
-	err = tree.ConvertText(fontdb)
+	err = tree.ConvertText(r.fontdb)
 	if err != nil {
 		return nil, fmt.Errorf("could not convert text: %w", err)
 	}
 
-	err = tree.Render(resvg.TransformIdentity(), pixmap)
+	transform := resvg.TransformIdentity()
+	if scale != 1 {
+		transform = resvg.TransformFromScale(float32(scale), float32(scale))
+	}
+	err = tree.Render(transform, pixmap)
 	if err != nil {
 		return nil, fmt.Errorf("could not render SVG: %w", err)
 	}
@@ -450,27 +647,29 @@ func (g *Generator) ConvertToPNG(svgData []byte, width, height float64) ([]byte,
 	return pngData, nil
 }
 
-// cutLines cuts the input to the specified line range
-func cutLines(input string, lines []int) string {
-	if len(lines) != 2 {
-		return input
+// lineNumber returns the original line number of the i-th rendered line
+func lineNumber(lineNumbers []int, i int) int {
+	if i < len(lineNumbers) {
+		return lineNumbers[i]
+	}
+	if len(lineNumbers) > 0 {
+		return lineNumbers[len(lineNumbers)-1] + i - len(lineNumbers) + 1
 	}
+	return i
+}
 
-	inputLines := strings.Split(input, "\n")
+// lineRange clamps a 0-indexed line selection to the available line count
+func lineRange(count int, lines []int) (int, int) {
 	start := lines[0]
 	end := lines[1]
 
 	if start < 0 {
 		start = 0
 	}
-	if end >= len(inputLines) || end < 0 {
-		end = len(inputLines) - 1
+	if end >= count || end < 0 {
+		end = count - 1
 	}
-	if start > end {
-		return ""
-	}
-
-	return strings.Join(inputLines[start:end+1], "\n")
+	return start, end
 }
 
 // max returns the maximum of two float64 values
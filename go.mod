@@ -6,12 +6,15 @@ toolchain go1.24.3
 
 require (
 	github.com/alecthomas/chroma/v2 v2.19.0
+	github.com/andybalholm/brotli v1.2.0
 	github.com/beevik/etree v1.5.1
 	github.com/charmbracelet/lipgloss v1.1.0
 	github.com/charmbracelet/x/ansi v0.9.3
 	github.com/charmbracelet/x/cellbuf v0.0.13
 	github.com/kanrichan/resvg-go v0.0.2-0.20231001163256-63db194ca9f5
 	github.com/mattn/go-runewidth v0.0.16
+	golang.org/x/image v0.25.0
+	golang.org/x/sys v0.34.0
 )
 
 require (
@@ -25,5 +28,5 @@ require (
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/tetratelabs/wazero v1.9.0 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
-	golang.org/x/sys v0.34.0 // indirect
+	golang.org/x/text v0.23.0 // indirect
 )
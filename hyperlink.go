@@ -0,0 +1,43 @@
+package freezelib
+
+import (
+	"net/url"
+	"strings"
+)
+
+// Hyperlinks configuration for OSC 8 hyperlinks in ANSI output
+type Hyperlinks struct {
+	// Strip renders linked text as plain text instead of wrapping it in
+	// SVG <a> elements
+	Strip bool `json:"strip"`
+	// Underline underlines linked text
+	Underline bool `json:"underline"`
+	// Color is the text color of links, the color set by the output when empty
+	Color string `json:"color"`
+}
+
+// linkSchemes are the URL schemes kept as links. Other links, such as
+// javascript: URLs, are rendered as plain text.
+var linkSchemes = map[string]bool{
+	"http":   true,
+	"https":  true,
+	"ftp":    true,
+	"mailto": true,
+	"file":   true,
+}
+
+// parseHyperlink returns the URI of an OSC 8 sequence, "8;params;uri", and
+// whether the sequence is a hyperlink. An empty URI ends the link.
+func parseHyperlink(data []byte) (string, bool) {
+	parts := strings.SplitN(string(data), ";", 3)
+	if len(parts) != 3 || parts[0] != "8" {
+		return "", false
+	}
+	return parts[2], true
+}
+
+// isSafeLink checks if a link uses one of the allowed URL schemes
+func isSafeLink(uri string) bool {
+	u, err := url.Parse(uri)
+	return err == nil && linkSchemes[strings.ToLower(u.Scheme)]
+}
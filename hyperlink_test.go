@@ -0,0 +1,56 @@
+package freezelib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHyperlinks(t *testing.T) {
+	input := "see \x1b]8;;https://example.com\x1b\\\x1b[32mexample\x1b[0m\x1b]8;;\x1b\\ and \x1b]8;;javascript:alert(1)\x07bad\x1b]8;;\x07"
+
+	tests := []struct {
+		name       string
+		hyperlinks Hyperlinks
+		expected   []string
+		unexpected []string
+	}{
+		{
+			name:       "Keep",
+			expected:   []string{`<a href="https://example.com"><tspan xml:space="preserve" x="53.60px" fill="#00FF00">example</tspan></a>`},
+			unexpected: []string{"javascript:"},
+		},
+		{
+			name:       "Link color",
+			hyperlinks: Hyperlinks{Color: "#0000ff"},
+			expected:   []string{`fill="#0000ff">example`},
+		},
+		{
+			name:       "Strip",
+			hyperlinks: Hyperlinks{Strip: true, Underline: true},
+			unexpected: []string{"<a ", "<rect x="},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svgData, err := NewGenerator(DefaultConfig().SetHyperlinks(tt.hyperlinks)).GenerateFromANSI(input)
+			if err != nil {
+				t.Fatalf("GenerateFromANSI failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(svgData), expected) {
+					t.Errorf("SVG should contain %s", expected)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(string(svgData), unexpected) {
+					t.Errorf("SVG should not contain %s", unexpected)
+				}
+			}
+		})
+	}
+
+	if err := DefaultConfig().SetHyperlinks(Hyperlinks{Color: "blue-ish"}).Validate(); err == nil {
+		t.Error("Validate() should fail for invalid hyperlink colors")
+	}
+}
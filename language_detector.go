@@ -175,11 +175,14 @@ func (ld *LanguageDetector) GetSupportedThemes() []string {
 	for name := range styles.Registry {
 		themes = append(themes, name)
 	}
-	return themes
+	return append(themes, customThemeNames()...)
 }
 
 // IsThemeSupported checks if a theme is supported
 func (ld *LanguageDetector) IsThemeSupported(theme string) bool {
+	if _, exists := getCustomTheme(theme); exists {
+		return true
+	}
 	_, exists := styles.Registry[strings.ToLower(theme)]
 	return exists
 }
@@ -0,0 +1,67 @@
+package freezelib
+
+import (
+	"github.com/landaiqing/freezelib/font"
+)
+
+// lineNumberCells is the number of cells taken by the line number prefix
+const lineNumberCells = 5
+
+// cellLayout maps terminal cells to SVG coordinates
+type cellLayout struct {
+	// x and y are the top-left corner of the first cell
+	x, y       float64
+	cellWidth  float64
+	lineHeight float64
+	// baseline is the distance from the top of a line to its baseline
+	baseline float64
+	// underline, strikethrough and overline are the distances from the top
+	// of a line to the center of its decoration lines
+	underline     float64
+	strikethrough float64
+	overline      float64
+	lineThickness float64
+}
+
+// newCellLayout creates the layout for text whose first baseline is at (x, baseline)
+func newCellLayout(metrics *font.Metrics, fontSize, lineHeight, x, baseline float64) cellLayout {
+	offset := metrics.BaselineOffset(fontSize, lineHeight)
+	return cellLayout{
+		x:          x,
+		y:          baseline - offset,
+		cellWidth:  metrics.CellWidth(fontSize),
+		lineHeight: lineHeight,
+		baseline:   offset,
+
+		underline:     offset + metrics.UnderlineOffset*fontSize,
+		strikethrough: offset - metrics.StrikeoutOffset*fontSize,
+		overline:      offset - metrics.OverlineOffset*fontSize,
+		lineThickness: metrics.LineThickness * fontSize,
+	}
+}
+
+// cellX returns the left edge of a column
+func (l cellLayout) cellX(col int) float64 {
+	return l.x + float64(col)*l.cellWidth
+}
+
+// cellY returns the top edge of a row
+func (l cellLayout) cellY(row int) float64 {
+	return l.y + float64(row)*l.lineHeight
+}
+
+// fontMetrics returns the metrics of the configured font file or registered
+// family, falling back to the embedded default font
+func (g *Generator) fontMetrics() *font.Metrics {
+	if g.config.Font.File != "" {
+		if metrics, err := font.LoadMetrics(g.config.Font.File); err == nil {
+			return metrics
+		}
+	}
+	if registered, ok := g.result.Font.Font(font.Regular); ok {
+		if metrics, err := font.ParseMetrics(registered.Data); err == nil {
+			return metrics
+		}
+	}
+	return font.DefaultMetrics()
+}
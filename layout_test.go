@@ -0,0 +1,69 @@
+package freezelib
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/landaiqing/freezelib/font"
+)
+
+func TestDefaultFontMetrics(t *testing.T) {
+	metrics := font.DefaultMetrics()
+
+	tests := []struct {
+		name     string
+		got      float64
+		expected float64
+	}{
+		// JetBrains Mono has 1000 units per em, 600 unit advances and 1020/300 ascent/descent
+		{"Advance", metrics.Advance, 0.6},
+		{"Ascent", metrics.Ascent, 1.02},
+		{"Descent", metrics.Descent, 0.3},
+		{"Cell width", metrics.CellWidth(20), 12},
+		{"ASCII text width", font.CalculateTextWidth("hello", 10), 30},
+		{"Wide text width", font.CalculateTextWidth("中文", 10), 24},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if math.Abs(tt.got-tt.expected) > 0.001 {
+				t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAutoWidthUsesFontMetrics(t *testing.T) {
+	config := DefaultConfig()
+	config.Padding = []float64{0}
+	config.Margin = []float64{0}
+
+	svgData, err := NewGenerator(config).GenerateFromCode(strings.Repeat("x", 19), "text")
+	if err != nil {
+		t.Fatalf("GenerateFromCode failed: %v", err)
+	}
+	// 19 characters plus one spare cell at 14px * 0.6
+	if !strings.Contains(string(svgData), `width="168.00"`) {
+		t.Error("SVG width should be 20 cells of 8.4px")
+	}
+}
+
+func TestFontFallbacks(t *testing.T) {
+	config := DefaultConfig()
+	config.SetFontFallbacks("Noto Sans CJK SC", "Symbola")
+
+	svgData, err := NewGenerator(config).GenerateFromCode("// 中文", "go")
+	if err != nil {
+		t.Fatalf("GenerateFromCode failed: %v", err)
+	}
+	expected := "font-family=\"&apos;JetBrains Mono&apos;, &apos;Noto Sans CJK SC&apos;, Symbola, monospace\""
+	if !strings.Contains(string(svgData), expected) {
+		t.Errorf("SVG should contain %s", expected)
+	}
+
+	config.SetFontFallbacks("missing-font.ttf")
+	if _, err := NewGenerator(config).GenerateFromCode("x", "go"); err == nil {
+		t.Error("GenerateFromCode should fail for a missing fallback font file")
+	}
+}
@@ -0,0 +1,252 @@
+package freezelib
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+)
+
+// Normalize configuration for source preprocessing
+type Normalize struct {
+	// LineEndings converts CRLF and CR line endings to LF and strips a leading BOM
+	LineEndings bool `json:"line_endings"`
+	// Dedent removes the indentation shared by all non-blank lines
+	Dedent bool `json:"dedent"`
+	// TrimBlankLines removes blank lines at the start and end of the input
+	TrimBlankLines bool `json:"trim_blank_lines"`
+	// CollapseBlankLines replaces runs of blank lines with a single blank line
+	CollapseBlankLines bool `json:"collapse_blank_lines"`
+}
+
+// source holds prepared input text together with its layout metadata
+type source struct {
+	// text is the processed input
+	text string
+	// lineNumbers holds the original (0-indexed) line number of every line, so
+	// line numbers stay consistent with the source even when lines are cut,
+	// trimmed or collapsed. Wrapped lines have the number -1.
+	lineNumbers []int
+	// redactions are the spans hidden behind boxes
+	redactions []redactedSpan
+}
+
+// prepareInput applies line selection, source normalization and redaction
+// to the input.
+func (g *Generator) prepareInput(input string) (source, error) {
+	config := g.config
+
+	if config.Normalize.LineEndings {
+		input = normalizeLineEndings(input)
+	}
+
+	// Find secrets in the whole input, so that secrets spanning lines
+	// outside the selected range, like the body of a private key whose
+	// header is not selected, are still detected
+	secrets, err := config.Redact.find(input)
+	if err != nil {
+		return source{}, err
+	}
+
+	original := strings.Split(input, "\n")
+	lines := original
+	numbers := make([]int, len(lines))
+	for i := range numbers {
+		numbers[i] = i
+	}
+
+	// Cut to the selected line range
+	if len(config.Lines) == 2 {
+		start, end := lineRange(len(lines), config.Lines)
+		if start > end {
+			return source{}, nil
+		}
+		lines = lines[start : end+1]
+		numbers = numbers[start : end+1]
+	}
+
+	if config.Normalize.Dedent {
+		lines = dedentLines(lines)
+	}
+
+	if config.Normalize.TrimBlankLines {
+		lines, numbers = trimBlankLines(lines, numbers)
+	}
+
+	if config.Normalize.CollapseBlankLines {
+		lines, numbers = collapseBlankLines(lines, numbers)
+	}
+
+	secrets = moveRanges(secrets, original, lines, numbers)
+	text, redactions := config.Redact.apply(strings.Join(lines, "\n"), secrets)
+
+	return source{text: text, lineNumbers: numbers, redactions: redactions}, nil
+}
+
+// wrap wraps the lines of the source at the given number of columns. The
+// lines are wrapped as terminal cells, so escape sequences are kept intact
+// and styles and hyperlinks continue on the wrapped lines. Wrapped lines
+// have no line number of their own, and redactions move with the wrapped
+// text.
+func (src source) wrap(columns int) source {
+	var lines []string
+	var numbers []int
+	var redactions []redactedSpan
+	for i, line := range strings.Split(src.text, "\n") {
+		col := 0
+		for j, cells := range wrapCells(interpret(line).line(0), columns) {
+			for _, span := range src.redactions {
+				start := clamp(span.col, col, col+len(cells))
+				end := clamp(span.col+span.width, col, col+len(cells))
+				if span.line == i && start < end {
+					redactions = append(redactions, redactedSpan{line: len(lines), col: start - col, width: end - start})
+				}
+			}
+
+			number := -1
+			if j == 0 {
+				number = lineNumber(src.lineNumbers, i)
+			}
+			lines = append(lines, (&screen{lines: [][]cell{cells}}).String())
+			numbers = append(numbers, number)
+			col += len(cells)
+		}
+	}
+	return source{text: strings.Join(lines, "\n"), lineNumbers: numbers, redactions: redactions}
+}
+
+// moveRanges maps byte ranges of the original lines onto the prepared lines,
+// which are original lines identified by their line numbers, with leading
+// whitespace removed
+func moveRanges(ranges [][2]int, original, lines []string, numbers []int) [][2]int {
+	starts := make([]int, len(original))
+	for i := 1; i < len(original); i++ {
+		starts[i] = starts[i-1] + len(original[i-1]) + 1
+	}
+
+	var moved [][2]int
+	offset := 0
+	for i, line := range lines {
+		n := numbers[i]
+		shift := starts[n] + len(original[n]) - len(line)
+		for _, rg := range ranges {
+			start := clamp(rg[0]-shift, 0, len(line))
+			end := clamp(rg[1]-shift, 0, len(line))
+			if start < end {
+				moved = append(moved, [2]int{offset + start, offset + end})
+			}
+		}
+		offset += len(line) + 1
+	}
+	return moved
+}
+
+// normalizeLineEndings strips a UTF-8 byte order mark and converts CRLF and
+// lone CR line endings to LF
+func normalizeLineEndings(input string) string {
+	input = strings.TrimPrefix(input, "\ufeff")
+	input = strings.ReplaceAll(input, "\r\n", "\n")
+	return strings.ReplaceAll(input, "\r", "\n")
+}
+
+// isBlankLine checks if a line only contains whitespace. Lines with escape
+// sequences are not blank, as styled spaces may have a background.
+func isBlankLine(line string) bool {
+	return strings.TrimSpace(line) == ""
+}
+
+// dedentLines removes the longest common leading whitespace from all non-blank
+// lines. Blank lines are emptied. The indent is measured on the text of the
+// lines without escape sequences, and lines that only show whitespace do not
+// count towards it.
+func dedentLines(lines []string) []string {
+	prefix := ""
+	found := false
+	for _, line := range lines {
+		text := ansi.Strip(line)
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		indent := text[:len(text)-len(strings.TrimLeft(text, " \t"))]
+		if !found {
+			prefix = indent
+			found = true
+			continue
+		}
+		prefix = commonPrefix(prefix, indent)
+		if prefix == "" {
+			break
+		}
+	}
+
+	result := make([]string, len(lines))
+	for i, line := range lines {
+		if isBlankLine(line) {
+			result[i] = ""
+			continue
+		}
+		result[i] = trimIndent(line, prefix)
+	}
+	return result
+}
+
+// trimIndent removes an indent from the start of the text of a line,
+// keeping the escape sequences before and within it
+func trimIndent(line, indent string) string {
+	var b strings.Builder
+	var state byte
+	for line != "" && indent != "" {
+		seq, width, n, newState := ansi.DecodeSequence(line, state, nil)
+		switch {
+		case strings.HasPrefix(indent, seq):
+			indent = indent[len(seq):]
+		case width == 0 && seq[0] == ansi.ESC:
+			b.WriteString(seq)
+		default:
+			return b.String() + line
+		}
+		state = newState
+		line = line[n:]
+	}
+	return b.String() + line
+}
+
+// commonPrefix returns the longest common prefix of a and b
+func commonPrefix(a, b string) string {
+	n := min(len(a), len(b))
+	for i := 0; i < n; i++ {
+		if a[i] != b[i] {
+			return a[:i]
+		}
+	}
+	return a[:n]
+}
+
+// trimBlankLines removes leading and trailing blank lines
+func trimBlankLines(lines []string, numbers []int) ([]string, []int) {
+	start := 0
+	for start < len(lines) && isBlankLine(lines[start]) {
+		start++
+	}
+	end := len(lines)
+	for end > start && isBlankLine(lines[end-1]) {
+		end--
+	}
+	return lines[start:end], numbers[start:end]
+}
+
+// collapseBlankLines replaces every run of blank lines with a single blank line
+func collapseBlankLines(lines []string, numbers []int) ([]string, []int) {
+	resultLines := make([]string, 0, len(lines))
+	resultNumbers := make([]int, 0, len(numbers))
+	previousBlank := false
+	for i, line := range lines {
+		blank := isBlankLine(line)
+		if blank && previousBlank {
+			continue
+		}
+		previousBlank = blank
+		resultLines = append(resultLines, line)
+		resultNumbers = append(resultNumbers, numbers[i])
+	}
+	return resultLines, resultNumbers
+}
@@ -0,0 +1,129 @@
+package freezelib
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrepareInput(t *testing.T) {
+	code := "\ufeff\r\n\r\n    func main() {\r\n        fmt.Println(1)\r\n\r\n\r\n\r\n        fmt.Println(2)\r\n    }\r\n\r\n"
+
+	tests := []struct {
+		name        string
+		normalize   Normalize
+		lines       []int
+		expected    string
+		lineNumbers []int
+	}{
+		{
+			name:        "Line endings only",
+			normalize:   Normalize{LineEndings: true},
+			expected:    "\n\n    func main() {\n        fmt.Println(1)\n\n\n\n        fmt.Println(2)\n    }\n\n",
+			lineNumbers: []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+		},
+		{
+			name:        "Trim and dedent",
+			normalize:   Normalize{LineEndings: true, Dedent: true, TrimBlankLines: true},
+			expected:    "func main() {\n    fmt.Println(1)\n\n\n\n    fmt.Println(2)\n}",
+			lineNumbers: []int{2, 3, 4, 5, 6, 7, 8},
+		},
+		{
+			name:        "All steps",
+			normalize:   Normalize{LineEndings: true, Dedent: true, TrimBlankLines: true, CollapseBlankLines: true},
+			expected:    "func main() {\n    fmt.Println(1)\n\n    fmt.Println(2)\n}",
+			lineNumbers: []int{2, 3, 4, 7, 8},
+		},
+		{
+			name:        "All steps with line selection",
+			normalize:   Normalize{LineEndings: true, Dedent: true, TrimBlankLines: true, CollapseBlankLines: true},
+			lines:       []int{3, 7},
+			expected:    "fmt.Println(1)\n\nfmt.Println(2)",
+			lineNumbers: []int{3, 4, 7},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Normalize = tt.normalize
+			config.Lines = tt.lines
+			src, err := NewGenerator(config).prepareInput(code)
+			if err != nil {
+				t.Fatalf("prepareInput() failed: %v", err)
+			}
+			if src.text != tt.expected {
+				t.Errorf("prepareInput() = %q, want %q", src.text, tt.expected)
+			}
+			if !reflect.DeepEqual(src.lineNumbers, tt.lineNumbers) {
+				t.Errorf("prepareInput() line numbers = %v, want %v", src.lineNumbers, tt.lineNumbers)
+			}
+		})
+	}
+}
+
+func TestStyledBlankLines(t *testing.T) {
+	lines := []string{"\x1b[41m  \x1b[0m", "\x1b[41m  \x1b[0m", "", "", "x", " "}
+	numbers := []int{0, 1, 2, 3, 4, 5}
+
+	trimmed, _ := trimBlankLines(lines, numbers)
+	if expected := lines[:5]; !reflect.DeepEqual(trimmed, expected) {
+		t.Errorf("trimBlankLines() = %q, want %q", trimmed, expected)
+	}
+	collapsed, _ := collapseBlankLines(lines, numbers)
+	if expected := []string{lines[0], lines[1], "", "x", " "}; !reflect.DeepEqual(collapsed, expected) {
+		t.Errorf("collapseBlankLines() = %q, want %q", collapsed, expected)
+	}
+}
+
+func TestDedentLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		lines    []string
+		expected []string
+	}{
+		{"Whitespace-only line", []string{"    if ok {", "  ", "        return", "    }"}, []string{"if ok {", "", "    return", "}"}},
+		{"Styled spaces", []string{"\t\x1b[1mbold\x1b[0m", "\t\x1b[41m \t\x1b[0m", "\tplain"}, []string{"\x1b[1mbold\x1b[0m", "\x1b[41m \t\x1b[0m", "plain"}},
+		{"Styled indent", []string{"\x1b[32m    green\x1b[0m", "    plain"}, []string{"\x1b[32mgreen\x1b[0m", "plain"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := dedentLines(tt.lines); !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("dedentLines() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestNormalizedLineNumbers(t *testing.T) {
+	config := DefaultConfig()
+	config.ShowLineNumbers = true
+	config.Normalize = Normalize{TrimBlankLines: true}
+
+	svgData, err := NewGenerator(config).GenerateFromCode("\n\npackage main\n", "go")
+	if err != nil {
+		t.Fatalf("GenerateFromCode failed: %v", err)
+	}
+	if !strings.Contains(string(svgData), "  3  ") {
+		t.Error("Line numbers should start at the first non-blank source line")
+	}
+}
+
+func TestSourceWrap(t *testing.T) {
+	src := source{
+		text:        "\x1b[0;31mone two three\x1b[m\nfour",
+		lineNumbers: []int{4, 5},
+		redactions:  []redactedSpan{{line: 0, col: 2, width: 5}},
+	}
+	wrapped := src.wrap(8)
+
+	if expected := "\x1b[0;31mone two \x1b[m\n\x1b[0;31mthree\x1b[m\nfour"; wrapped.text != expected {
+		t.Errorf("wrap() text = %q, want %q", wrapped.text, expected)
+	}
+	if expected := []int{4, -1, 5}; !reflect.DeepEqual(wrapped.lineNumbers, expected) {
+		t.Errorf("wrap() line numbers = %v, want %v", wrapped.lineNumbers, expected)
+	}
+	if expected := []redactedSpan{{line: 0, col: 2, width: 5}}; !reflect.DeepEqual(wrapped.redactions, expected) {
+		t.Errorf("wrap() redactions = %v, want %v", wrapped.redactions, expected)
+	}
+}
@@ -0,0 +1,352 @@
+package freezelib
+
+import (
+	"bufio"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+)
+
+// TerminalPalette describes the colors used to render ANSI output
+type TerminalPalette struct {
+	Name string `json:"name"`
+	// Colors holds the 16 base colors: black, red, green, yellow, blue,
+	// magenta, cyan and white followed by their bright variants
+	Colors [16]string `json:"colors"`
+	// Foreground is the default text color, the theme's text color when empty
+	Foreground string `json:"foreground"`
+	// Background is the default background color, Config.Background when empty
+	Background string `json:"background"`
+	// Cursor is the cursor color
+	Cursor string `json:"cursor"`
+}
+
+// defaultTerminalColors are used for palette entries that are not set
+var defaultTerminalColors = [16]string{
+	"#000000", // black
+	"#FF0000", // red
+	"#00FF00", // green
+	"#FFFF00", // yellow
+	"#0000FF", // blue
+	"#FF00FF", // magenta
+	"#00FFFF", // cyan
+	"#FFFFFF", // white
+	"#808080", // bright black (gray)
+	"#FF8080", // bright red
+	"#80FF80", // bright green
+	"#FFFF80", // bright yellow
+	"#8080FF", // bright blue
+	"#FF80FF", // bright magenta
+	"#80FFFF", // bright cyan
+	"#FFFFFF", // bright white
+}
+
+// terminalPalettes are the bundled terminal palettes
+var terminalPalettes = map[string]TerminalPalette{
+	"xterm": {
+		Name: "xterm",
+		Colors: [16]string{
+			"#000000", "#cd0000", "#00cd00", "#cdcd00", "#0000ee", "#cd00cd", "#00cdcd", "#e5e5e5",
+			"#7f7f7f", "#ff0000", "#00ff00", "#ffff00", "#5c5cff", "#ff00ff", "#00ffff", "#ffffff",
+		},
+		Foreground: "#e5e5e5",
+		Background: "#000000",
+		Cursor:     "#e5e5e5",
+	},
+	"solarized-dark": {
+		Name: "solarized-dark",
+		Colors: [16]string{
+			"#073642", "#dc322f", "#859900", "#b58900", "#268bd2", "#d33682", "#2aa198", "#eee8d5",
+			"#002b36", "#cb4b16", "#586e75", "#657b83", "#839496", "#6c71c4", "#93a1a1", "#fdf6e3",
+		},
+		Foreground: "#839496",
+		Background: "#002b36",
+		Cursor:     "#93a1a1",
+	},
+	"solarized-light": {
+		Name: "solarized-light",
+		Colors: [16]string{
+			"#073642", "#dc322f", "#859900", "#b58900", "#268bd2", "#d33682", "#2aa198", "#eee8d5",
+			"#002b36", "#cb4b16", "#586e75", "#657b83", "#839496", "#6c71c4", "#93a1a1", "#fdf6e3",
+		},
+		Foreground: "#657b83",
+		Background: "#fdf6e3",
+		Cursor:     "#586e75",
+	},
+	"dracula": {
+		Name: "dracula",
+		Colors: [16]string{
+			"#21222c", "#ff5555", "#50fa7b", "#f1fa8c", "#bd93f9", "#ff79c6", "#8be9fd", "#f8f8f2",
+			"#6272a4", "#ff6e6e", "#69ff94", "#ffffa5", "#d6acff", "#ff92df", "#a4ffff", "#ffffff",
+		},
+		Foreground: "#f8f8f2",
+		Background: "#282a36",
+		Cursor:     "#f8f8f2",
+	},
+	"nord": {
+		Name: "nord",
+		Colors: [16]string{
+			"#3b4252", "#bf616a", "#a3be8c", "#ebcb8b", "#81a1c1", "#b48ead", "#88c0d0", "#e5e9f0",
+			"#4c566a", "#bf616a", "#a3be8c", "#ebcb8b", "#81a1c1", "#b48ead", "#8fbcbb", "#eceff4",
+		},
+		Foreground: "#d8dee9",
+		Background: "#2e3440",
+		Cursor:     "#d8dee9",
+	},
+	"github-dark": {
+		Name: "github-dark",
+		Colors: [16]string{
+			"#484f58", "#ff7b72", "#3fb950", "#d29922", "#58a6ff", "#bc8cff", "#39c5cf", "#b1bac4",
+			"#6e7681", "#ffa198", "#56d364", "#e3b341", "#79c0ff", "#d2a8ff", "#56d4dd", "#ffffff",
+		},
+		Foreground: "#e6edf3",
+		Background: "#0d1117",
+		Cursor:     "#2f81f7",
+	},
+	"tango": {
+		Name: "tango",
+		Colors: [16]string{
+			"#000000", "#cc0000", "#4e9a06", "#c4a000", "#3465a4", "#75507b", "#06989a", "#d3d7cf",
+			"#555753", "#ef2929", "#8ae234", "#fce94f", "#729fcf", "#ad7fa8", "#34e2e2", "#eeeeec",
+		},
+		Foreground: "#d3d7cf",
+		Background: "#2e3436",
+		Cursor:     "#d3d7cf",
+	},
+}
+
+// GetTerminalPalette returns a bundled terminal palette by name
+func GetTerminalPalette(name string) (TerminalPalette, bool) {
+	palette, ok := terminalPalettes[strings.ToLower(name)]
+	return palette, ok
+}
+
+// GetTerminalPalettes returns a sorted list of the bundled terminal palettes
+func GetTerminalPalettes() []string {
+	var names []string
+	for name := range terminalPalettes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// normalize returns the palette with every color that is set converted to
+// #rrggbb, failing for colors that cannot be parsed
+func (tp TerminalPalette) normalize() (TerminalPalette, error) {
+	colors := append(tp.Colors[:], tp.Foreground, tp.Background, tp.Cursor)
+	for i, color := range colors {
+		if color == "" {
+			continue
+		}
+		normalized, ok := normalizeColor(strings.TrimSpace(color))
+		if !ok {
+			return TerminalPalette{}, fmt.Errorf("invalid terminal palette color %q", color)
+		}
+		colors[i] = normalized
+	}
+	copy(tp.Colors[:], colors)
+	tp.Foreground, tp.Background, tp.Cursor = colors[16], colors[17], colors[18]
+	return tp, nil
+}
+
+// withDefaults returns the normalized palette with the default foreground
+// and background colors taken from the theme when they are not set
+func (tp TerminalPalette) withDefaults(style *chroma.Style, background string) TerminalPalette {
+	// Invalid colors are rejected by Config.Validate
+	if normalized, err := tp.normalize(); err == nil {
+		tp = normalized
+	}
+	theme := style.Get(chroma.Background)
+	if tp.Background == "" {
+		tp.Background = background
+		if theme.Background.IsSet() {
+			tp.Background = theme.Background.String()
+		}
+	}
+	if tp.Foreground == "" {
+		switch text := style.Get(chroma.Text); {
+		case text.Colour.IsSet():
+			tp.Foreground = text.Colour.String()
+		case theme.Colour.IsSet():
+			tp.Foreground = theme.Colour.String()
+		case chroma.ParseColour(tp.Background).Brightness() > 0.5:
+			tp.Foreground = tp.color(0)
+		default:
+			tp.Foreground = tp.color(7)
+		}
+	}
+	return tp
+}
+
+// color returns the color of a 256-color palette index. The first 16 colors
+// come from the terminal palette.
+func (tp TerminalPalette) color(n int) string {
+	if n < 0 || n >= len(palette) {
+		return ""
+	}
+	if n < 16 {
+		if tp.Colors[n] != "" {
+			return tp.Colors[n]
+		}
+		return defaultTerminalColors[n]
+	}
+	return palette[n]
+}
+
+// LoadTerminalPaletteFile loads a terminal palette from a file, choosing the
+// format from the extension: iTerm2 (.itermcolors), Windows Terminal color
+// schemes (.json) and Xresources (anything else).
+func LoadTerminalPaletteFile(filename string) (TerminalPalette, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return TerminalPalette{}, fmt.Errorf("failed to open palette: %w", err)
+	}
+	defer f.Close()
+
+	var tp TerminalPalette
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".itermcolors":
+		tp, err = LoadITermColors(f)
+	case ".json":
+		tp, err = LoadWindowsTerminalScheme(f)
+	default:
+		tp, err = LoadXresources(f)
+	}
+	if err != nil {
+		return TerminalPalette{}, err
+	}
+	if tp.Name == "" {
+		tp.Name = strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+	}
+	return tp, nil
+}
+
+// LoadITermColors loads an iTerm2 color preset (.itermcolors)
+func LoadITermColors(r io.Reader) (TerminalPalette, error) {
+	root, err := decodePlist(xml.NewDecoder(r))
+	if err != nil {
+		return TerminalPalette{}, fmt.Errorf("failed to parse iTerm2 colors: %w", err)
+	}
+	dict, ok := root.(map[string]any)
+	if !ok {
+		return TerminalPalette{}, errors.New("failed to parse iTerm2 colors: root is not a dictionary")
+	}
+
+	color := func(key string) string {
+		components, ok := dict[key].(map[string]any)
+		if !ok {
+			return ""
+		}
+		component := func(name string) int {
+			value, _ := components[name+" Component"].(string)
+			f, _ := strconv.ParseFloat(value, 64)
+			return int(f*255 + 0.5)
+		}
+		return fmt.Sprintf("#%02x%02x%02x", component("Red"), component("Green"), component("Blue"))
+	}
+
+	var tp TerminalPalette
+	for i := range tp.Colors {
+		tp.Colors[i] = color(fmt.Sprintf("Ansi %d Color", i))
+	}
+	tp.Foreground = color("Foreground Color")
+	tp.Background = color("Background Color")
+	tp.Cursor = color("Cursor Color")
+	return tp.normalize()
+}
+
+// windowsTerminalColorNames are the Windows Terminal color scheme keys in palette order
+var windowsTerminalColorNames = [16]string{
+	"black", "red", "green", "yellow", "blue", "purple", "cyan", "white",
+	"brightBlack", "brightRed", "brightGreen", "brightYellow", "brightBlue", "brightPurple", "brightCyan", "brightWhite",
+}
+
+// LoadWindowsTerminalScheme loads a Windows Terminal color scheme (JSON)
+func LoadWindowsTerminalScheme(r io.Reader) (TerminalPalette, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return TerminalPalette{}, fmt.Errorf("failed to read palette: %w", err)
+	}
+
+	var scheme map[string]string
+	if err := json.Unmarshal(stripJSONComments(data), &scheme); err != nil {
+		return TerminalPalette{}, fmt.Errorf("failed to parse Windows Terminal scheme: %w", err)
+	}
+
+	tp := TerminalPalette{
+		Name:       scheme["name"],
+		Foreground: scheme["foreground"],
+		Background: scheme["background"],
+		Cursor:     scheme["cursorColor"],
+	}
+	for i, name := range windowsTerminalColorNames {
+		tp.Colors[i] = scheme[name]
+	}
+	return tp.normalize()
+}
+
+// LoadXresources loads terminal colors from an Xresources file. Resources
+// such as "*.color0", "URxvt*color1", "*.foreground" and "*.cursorColor"
+// are recognised, as well as simple #define macros.
+func LoadXresources(r io.Reader) (TerminalPalette, error) {
+	var tp TerminalPalette
+	defines := map[string]string{}
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "!") {
+			continue
+		}
+		if strings.HasPrefix(line, "#define") {
+			fields := strings.Fields(line)
+			if len(fields) >= 3 {
+				defines[fields[1]] = fields[2]
+			}
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		if defined, ok := defines[value]; ok {
+			value = defined
+		}
+
+		// Only the last component of the resource name matters
+		key = strings.TrimSpace(key)
+		if i := strings.LastIndexAny(key, ".*"); i >= 0 {
+			key = key[i+1:]
+		}
+
+		switch {
+		case key == "foreground":
+			tp.Foreground = value
+		case key == "background":
+			tp.Background = value
+		case key == "cursorColor":
+			tp.Cursor = value
+		case strings.HasPrefix(key, "color"):
+			n, err := strconv.Atoi(strings.TrimPrefix(key, "color"))
+			if err == nil && n >= 0 && n < len(tp.Colors) {
+				tp.Colors[n] = value
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return TerminalPalette{}, fmt.Errorf("failed to read Xresources: %w", err)
+	}
+	return tp.normalize()
+}
@@ -0,0 +1,142 @@
+package freezelib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestTerminalPaletteColor(t *testing.T) {
+	dracula, ok := GetTerminalPalette("Dracula")
+	if !ok {
+		t.Fatal("GetTerminalPalette(\"Dracula\") not found")
+	}
+
+	tests := []struct {
+		name     string
+		palette  TerminalPalette
+		index    int
+		expected string
+	}{
+		{"Default red", TerminalPalette{}, 1, "#FF0000"},
+		{"Palette red", dracula, 1, "#ff5555"},
+		{"Palette bright blue", dracula, 12, "#d6acff"},
+		{"256 color cube", dracula, 196, "#ff0000"},
+		{"Out of range", dracula, 256, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.palette.color(tt.index); got != tt.expected {
+				t.Errorf("color(%d) = %q, want %q", tt.index, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLoadTerminalPalettes(t *testing.T) {
+	iterm := `<?xml version="1.0" encoding="UTF-8"?>
+<plist version="1.0">
+<dict>
+	<key>Ansi 1 Color</key>
+	<dict>
+		<key>Blue Component</key><real>0.0</real>
+		<key>Green Component</key><real>0.0</real>
+		<key>Red Component</key><real>1</real>
+	</dict>
+	<key>Background Color</key>
+	<dict>
+		<key>Blue Component</key><real>0.2</real>
+		<key>Green Component</key><real>0.2</real>
+		<key>Red Component</key><real>0.2</real>
+	</dict>
+</dict>
+</plist>`
+
+	windowsTerminal := `{
+	// exported from settings.json
+	"name": "Campbell",
+	"red": "#C50F1F",
+	"brightBlue": "#3B78FF",
+	"foreground": "#CCCCCC",
+	"background": "#0C0C0C",
+	"cursorColor": "#FFFFFF"
+}`
+
+	xresources := `! comment
+#define red #aa0000
+*.foreground: rgb:dd/dd/dd
+URxvt*background: rgb:1/1/1
+*.color1: red
+*color12:   #5555ff
+*.cursorColor: #fff`
+
+	tests := []struct {
+		name     string
+		load     func(string) (TerminalPalette, error)
+		input    string
+		index    int
+		color    string
+		expected TerminalPalette
+	}{
+		{
+			name:  "iTerm2",
+			load:  func(s string) (TerminalPalette, error) { return LoadITermColors(strings.NewReader(s)) },
+			input: iterm,
+			index: 1, color: "#ff0000",
+			expected: TerminalPalette{Background: "#333333"},
+		},
+		{
+			name:  "Windows Terminal",
+			load:  func(s string) (TerminalPalette, error) { return LoadWindowsTerminalScheme(strings.NewReader(s)) },
+			input: windowsTerminal,
+			index: 12, color: "#3b78ff",
+			expected: TerminalPalette{Name: "Campbell", Foreground: "#cccccc", Background: "#0c0c0c", Cursor: "#ffffff"},
+		},
+		{
+			name:  "Xresources",
+			load:  func(s string) (TerminalPalette, error) { return LoadXresources(strings.NewReader(s)) },
+			input: xresources,
+			index: 1, color: "#aa0000",
+			expected: TerminalPalette{Foreground: "#dddddd", Background: "#111111", Cursor: "#ffffff"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tp, err := tt.load(tt.input)
+			if err != nil {
+				t.Fatalf("load failed: %v", err)
+			}
+			if tp.Colors[tt.index] != tt.color {
+				t.Errorf("Colors[%d] = %q, want %q", tt.index, tp.Colors[tt.index], tt.color)
+			}
+			if tp.Name != tt.expected.Name || tp.Foreground != tt.expected.Foreground ||
+				tp.Background != tt.expected.Background || tp.Cursor != tt.expected.Cursor {
+				t.Errorf("palette = %+v, want %+v", tp, tt.expected)
+			}
+		})
+	}
+
+	if _, err := LoadXresources(strings.NewReader("*.color1: crimson")); err == nil {
+		t.Error("LoadXresources() should fail for invalid colors")
+	}
+	if err := DefaultConfig().SetTerminalPalette(TerminalPalette{Foreground: "rgb:12/34"}).Validate(); err == nil {
+		t.Error("Validate() should fail for invalid terminal palette colors")
+	}
+}
+
+func TestTerminalPaletteRendering(t *testing.T) {
+	config := DefaultConfig()
+	nord, _ := GetTerminalPalette("nord")
+	config.SetTerminalPalette(nord)
+
+	svgData, err := NewGenerator(config).GenerateFromANSI("\x1b[31mred\x1b[0m plain")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+	for _, color := range []string{nord.Colors[1], nord.Foreground, nord.Background} {
+		if !strings.Contains(string(svgData), color) {
+			t.Errorf("SVG should contain palette color %s", color)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package freezelib
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/cellbuf"
+)
+
+// Prompt configures the shell prompt lines prepended to ANSI output, such
+// as "user@host ~/src ❯ make"
+type Prompt struct {
+	// Commands are shown after the prompt, one line each. No prompt lines
+	// are added when empty.
+	Commands []string `json:"commands"`
+	// User and Host are shown as user@host, and Dir is the working
+	// directory. Each is left out when empty.
+	User string `json:"user"`
+	Host string `json:"host"`
+	Dir  string `json:"dir"`
+	// Symbol ends the prompt, "$" when empty
+	Symbol string `json:"symbol"`
+	// Right is shown at the right edge of the prompt lines, such as the
+	// time or a git branch. It is left out when it does not fit.
+	Right string `json:"right"`
+	// Colors are terminal palette indexes such as "2" or colors such as
+	// "#50fa7b". By default the user and host are green, the directory is
+	// blue, the right prompt is bright black and the rest uses the
+	// foreground color.
+	UserColor    string `json:"user_color"`
+	DirColor     string `json:"dir_color"`
+	SymbolColor  string `json:"symbol_color"`
+	CommandColor string `json:"command_color"`
+	RightColor   string `json:"right_color"`
+}
+
+// validate checks the prompt colors
+func (p Prompt) validate() error {
+	for _, color := range []string{p.UserColor, p.DirColor, p.SymbolColor, p.CommandColor, p.RightColor} {
+		if _, err := promptColor(color, -1); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// promptColor converts a prompt color to a terminal color, using the
+// palette color fallback when empty, or the foreground color (nil) when
+// fallback is negative
+func promptColor(color string, fallback int) (ansi.Color, error) {
+	if color == "" {
+		if fallback < 0 {
+			return nil, nil
+		}
+		return ansi.ExtendedColor(fallback), nil
+	}
+	if n, err := strconv.Atoi(color); err == nil {
+		if n < 0 || n > 255 {
+			return nil, fmt.Errorf("invalid prompt color %q", color)
+		}
+		return ansi.ExtendedColor(n), nil
+	}
+	c := chroma.ParseColour(color)
+	if !c.IsSet() {
+		return nil, fmt.Errorf("invalid prompt color %q", color)
+	}
+	return ansi.RGBColor{R: c.Red(), G: c.Green(), B: c.Blue()}, nil
+}
+
+// line returns the prompt line of a command, styled with SGR sequences.
+// The right prompt is aligned to the given number of columns.
+func (p Prompt) line(command string, columns int) string {
+	var b strings.Builder
+	width := 0
+	write := func(text, color string, fallback int, bold bool) {
+		fg, _ := promptColor(color, fallback)
+		style := cellStyle{Style: cellbuf.Style{Fg: fg}}
+		style.Bold(bold)
+		b.WriteString(style.sgr())
+		b.WriteString(text)
+		width += ansi.StringWidth(text)
+	}
+
+	userHost := p.User
+	if p.User != "" && p.Host != "" {
+		userHost += "@"
+	}
+	userHost += p.Host
+	if userHost != "" {
+		write(userHost, p.UserColor, 2, true)
+		write(" ", "", -1, false)
+	}
+	if p.Dir != "" {
+		write(p.Dir, p.DirColor, 4, true)
+		write(" ", "", -1, false)
+	}
+	symbol := p.Symbol
+	if symbol == "" {
+		symbol = "$"
+	}
+	write(symbol, p.SymbolColor, -1, false)
+	write(" ", "", -1, false)
+	write(command, p.CommandColor, -1, false)
+
+	if p.Right != "" {
+		if gap := columns - width - ansi.StringWidth(p.Right); gap > 0 {
+			write(strings.Repeat(" ", gap), "", -1, false)
+			write(p.Right, p.RightColor, 8, false)
+		}
+	}
+	b.WriteString("\x1b[m")
+	return b.String()
+}
+
+// prepend adds the prompt lines of the commands before ANSI output. The
+// right prompt is aligned to the given number of columns, or to the widest
+// line of the output when 0.
+func (p Prompt) prepend(commands []string, output string, columns int) string {
+	if len(commands) == 0 {
+		return output
+	}
+	if columns == 0 {
+		columns = emulate(output, Terminal{}).width()
+	}
+
+	lines := make([]string, len(commands))
+	for i, command := range commands {
+		lines[i] = p.line(command, columns)
+	}
+	if output == "" {
+		return strings.Join(lines, "\n")
+	}
+	return strings.Join(lines, "\n") + "\n" + output
+}
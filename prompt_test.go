@@ -0,0 +1,57 @@
+package freezelib
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPromptLine(t *testing.T) {
+	tests := []struct {
+		name     string
+		prompt   Prompt
+		columns  int
+		expected string
+	}{
+		{"Default", Prompt{}, 0, "$ ls"},
+		{"User and host", Prompt{User: "me", Host: "box", Dir: "~/src", Symbol: "❯"}, 0, "me@box ~/src ❯ ls"},
+		{"Right", Prompt{Dir: "~", Right: "main"}, 16, "~ $ ls      main"},
+		{"Right does not fit", Prompt{Dir: "~", Right: "main"}, 10, "~ $ ls"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			line := tt.prompt.line("ls", tt.columns)
+			if got := stripANSI(line); got != tt.expected {
+				t.Errorf("line() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+
+	line := Prompt{User: "me", DirColor: "#ff0000", Dir: "/", Right: "12:00", RightColor: "3"}.line("ls", 20)
+	for _, expected := range []string{"\x1b[0;1;38;5;2mme", "\x1b[0;1;38;2;255;0;0m/", "\x1b[0;38;5;3m12:00"} {
+		if !strings.Contains(line, expected) {
+			t.Errorf("line() = %q, should contain %q", line, expected)
+		}
+	}
+
+	if err := DefaultConfig().SetPrompt(Prompt{SymbolColor: "nope"}).Validate(); err == nil {
+		t.Error("Validate() should fail for invalid prompt colors")
+	}
+}
+
+func TestGenerateFromANSIWithPrompt(t *testing.T) {
+	config := DefaultConfig().SetPrompt(Prompt{Commands: []string{"cd src", "make"}, Dir: "~", Right: "main"})
+	svgData, err := NewGenerator(config).GenerateFromANSI("\x1b[32mok\x1b[0m done")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+
+	text := stripANSI(config.Prompt.prepend(config.Prompt.Commands, "ok done", 0))
+	if expected := "~ $ cd src\n~ $ make\nok done"; text != expected {
+		t.Errorf("prepend() = %q, want %q", text, expected)
+	}
+	for _, expected := range []string{`> $ cd src</tspan>`, `> $ make</tspan>`, `fill="#00FF00">ok`} {
+		if !strings.Contains(string(svgData), expected) {
+			t.Errorf("SVG should contain %s", expected)
+		}
+	}
+}
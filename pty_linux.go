@@ -0,0 +1,73 @@
+//go:build linux
+
+package freezelib
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// openPTY opens a pseudo-terminal of the given size and returns its master
+// and slave ends
+func openPTY(columns, rows int) (*os.File, *os.File, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	// Unlock the slave end and look up its number. The ioctls go through
+	// the raw connection as Fd would switch the master to blocking mode,
+	// where closing it no longer interrupts reads.
+	conn, err := master.SyscallConn()
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+	var n int
+	var ioctlErr error
+	err = conn.Control(func(fd uintptr) {
+		if ioctlErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); ioctlErr != nil {
+			return
+		}
+		n, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+	})
+	if err == nil {
+		err = ioctlErr
+	}
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	slave, err := os.OpenFile("/dev/pts/"+strconv.Itoa(n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, nil, fmt.Errorf("failed to open pseudo-terminal: %w", err)
+	}
+
+	size := &unix.Winsize{Col: uint16(columns), Row: uint16(rows)}
+	if err := unix.IoctlSetWinsize(int(slave.Fd()), unix.TIOCSWINSZ, size); err != nil {
+		master.Close()
+		slave.Close()
+		return nil, nil, fmt.Errorf("failed to set pseudo-terminal size: %w", err)
+	}
+	return master, slave, nil
+}
+
+// setControllingTerminal makes the command's standard input its controlling
+// terminal, in a new session
+func setControllingTerminal(attr *syscall.SysProcAttr) {
+	attr.Setsid = true
+	attr.Setctty = true
+	attr.Ctty = 0
+}
+
+// killProcessGroup kills the process and the processes it started in its
+// session
+func killProcessGroup(p *os.Process) error {
+	return syscall.Kill(-p.Pid, syscall.SIGKILL)
+}
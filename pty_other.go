@@ -0,0 +1,22 @@
+//go:build !linux
+
+package freezelib
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// openPTY opens a pseudo-terminal, which is only supported on Linux
+func openPTY(columns, rows int) (*os.File, *os.File, error) {
+	return nil, nil, errors.New("capturing commands is only supported on Linux")
+}
+
+// setControllingTerminal does nothing without pseudo-terminal support
+func setControllingTerminal(attr *syscall.SysProcAttr) {}
+
+// killProcessGroup kills the process
+func killProcessGroup(p *os.Process) error {
+	return p.Kill()
+}
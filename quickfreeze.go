@@ -1,8 +1,12 @@
 package freezelib
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os/exec"
 	"strings"
+	"time"
 )
 
 // QuickFreeze provides a simplified, chainable API for quick code screenshots
@@ -30,12 +34,44 @@ func (qf *QuickFreeze) WithTheme(theme string) *QuickFreeze {
 	return qf
 }
 
+// WithThemeOverride overrides the style of a single token type on top of the theme
+func (qf *QuickFreeze) WithThemeOverride(tokenType string, style TokenStyle) *QuickFreeze {
+	qf.config.SetThemeOverride(tokenType, style)
+	return qf
+}
+
 // WithFont sets the font family and size
 func (qf *QuickFreeze) WithFont(family string, size float64) *QuickFreeze {
 	qf.config.SetFont(family, size)
 	return qf
 }
 
+// WithEmbeddedFont embeds the bundled font, subset to the rendered text,
+// into SVG output
+func (qf *QuickFreeze) WithEmbeddedFont() *QuickFreeze {
+	qf.config.SetFontEmbedding(true, true, qf.config.Font.WOFF2)
+	return qf
+}
+
+// WithoutEmbeddedFont references the font by name only in SVG output
+func (qf *QuickFreeze) WithoutEmbeddedFont() *QuickFreeze {
+	qf.config.Font.Embed = false
+	return qf
+}
+
+// WithFontFallbacks sets fallback font files or family names for glyphs
+// missing from the main font
+func (qf *QuickFreeze) WithFontFallbacks(fallbacks ...string) *QuickFreeze {
+	qf.config.SetFontFallbacks(fallbacks...)
+	return qf
+}
+
+// WithFontDirs adds directories searched for font families
+func (qf *QuickFreeze) WithFontDirs(dirs ...string) *QuickFreeze {
+	qf.config.SetFontDirs(dirs...)
+	return qf
+}
+
 // WithBackground sets the background color
 func (qf *QuickFreeze) WithBackground(color string) *QuickFreeze {
 	qf.config.SetBackground(color)
@@ -156,6 +192,76 @@ func (qf *QuickFreeze) WithWrap(columns int) *QuickFreeze {
 	return qf
 }
 
+// WithNormalize enables all source normalization steps
+// (line endings, dedent, blank line trimming and collapsing)
+func (qf *QuickFreeze) WithNormalize() *QuickFreeze {
+	qf.config.SetNormalize(Normalize{
+		LineEndings:        true,
+		Dedent:             true,
+		TrimBlankLines:     true,
+		CollapseBlankLines: true,
+	})
+	return qf
+}
+
+// WithRedact hides secrets using the built-in detectors and the given mode
+// ("mask", "box" or "blur")
+func (qf *QuickFreeze) WithRedact(mode string) *QuickFreeze {
+	qf.config.SetRedact(true, mode)
+	return qf
+}
+
+// WithTerminalPalette sets a bundled terminal palette (e.g. "dracula" or "nord")
+// for ANSI output. Unknown names are ignored.
+func (qf *QuickFreeze) WithTerminalPalette(name string) *QuickFreeze {
+	if palette, ok := GetTerminalPalette(name); ok {
+		qf.config.SetTerminalPalette(palette)
+	}
+	return qf
+}
+
+// WithHyperlinks keeps OSC 8 hyperlinks in ANSI output as clickable
+// links, underlined when underline is set
+func (qf *QuickFreeze) WithHyperlinks(underline bool) *QuickFreeze {
+	qf.config.Hyperlinks.Strip = false
+	qf.config.Hyperlinks.Underline = underline
+	return qf
+}
+
+// WithoutHyperlinks renders OSC 8 hyperlinks in ANSI output as plain text
+func (qf *QuickFreeze) WithoutHyperlinks() *QuickFreeze {
+	qf.config.Hyperlinks.Strip = true
+	return qf
+}
+
+// WithTerminalSize emulates a terminal of the given size for ANSI output,
+// showing only the last rows lines when crop is set
+func (qf *QuickFreeze) WithTerminalSize(columns, rows int, crop bool) *QuickFreeze {
+	qf.config.Terminal = Terminal{Columns: columns, Rows: rows, Crop: crop}
+	return qf
+}
+
+// WithAnimation sets the playback speed, the longest pause in seconds and
+// the number of loops of animated output, where 0 loops plays forever
+func (qf *QuickFreeze) WithAnimation(speed, idleTimeLimit float64, loops int) *QuickFreeze {
+	qf.config.Animation = Animation{Speed: speed, IdleTimeLimit: idleTimeLimit, Loops: loops}
+	return qf
+}
+
+// WithPrompt prepends shell prompt lines showing the prompt's commands to
+// ANSI output
+func (qf *QuickFreeze) WithPrompt(prompt Prompt) *QuickFreeze {
+	qf.config.Prompt = prompt
+	return qf
+}
+
+// WithCursor draws a "block", "underline" or "bar" cursor on ANSI output,
+// blinking in SVG output when blink is set
+func (qf *QuickFreeze) WithCursor(shape string, blink bool) *QuickFreeze {
+	qf.config.Cursor = Cursor{Shape: shape, Blink: blink}
+	return qf
+}
+
 // CodeToSVG generates SVG from source code
 func (qf *QuickFreeze) CodeToSVG(code string) ([]byte, error) {
 	generator := NewGenerator(qf.config)
@@ -291,6 +397,34 @@ func (qf *QuickFreeze) ANSIToSVG(ansiOutput string) ([]byte, error) {
 	return generator.GenerateFromANSI(ansiOutput)
 }
 
+// CastToSVG generates SVG from an asciinema recording at the given time, or
+// at its end when at is 0
+func (qf *QuickFreeze) CastToSVG(r io.Reader, at time.Duration) ([]byte, error) {
+	generator := NewGenerator(qf.config)
+	return generator.GenerateFromCast(r, at)
+}
+
+// CommandToSVG runs a command in a pseudo-terminal and generates SVG from
+// its output below a prompt line showing the command
+func (qf *QuickFreeze) CommandToSVG(ctx context.Context, cmd *exec.Cmd, opts CaptureOptions) ([]byte, error) {
+	generator := NewGenerator(qf.config)
+	return generator.GenerateFromCommand(ctx, cmd, opts)
+}
+
+// ANSIToAnimation generates an animation in the given format (AnimatedSVG,
+// AnimatedGIF or AnimatedPNG) from timed chunks of ANSI output
+func (qf *QuickFreeze) ANSIToAnimation(chunks []Chunk, format string) ([]byte, error) {
+	generator := NewGenerator(qf.config)
+	return generator.GenerateAnimation(chunks, format)
+}
+
+// CastToAnimation generates an animation in the given format from an
+// asciinema recording
+func (qf *QuickFreeze) CastToAnimation(r io.Reader, format string) ([]byte, error) {
+	generator := NewGenerator(qf.config)
+	return generator.GenerateAnimationFromCast(r, format)
+}
+
 // ANSIToPNG generates PNG from ANSI terminal output
 func (qf *QuickFreeze) ANSIToPNG(ansiOutput string) ([]byte, error) {
 	generator := NewGenerator(qf.config)
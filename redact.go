@@ -0,0 +1,233 @@
+package freezelib
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/beevik/etree"
+	"github.com/charmbracelet/x/ansi"
+	"github.com/landaiqing/freezelib/svg"
+)
+
+// Redaction modes
+const (
+	// RedactMask replaces every redacted character with the mask character
+	RedactMask = "mask"
+	// RedactBox hides redacted text behind a solid box
+	RedactBox = "box"
+	// RedactBlur hides redacted text behind a blurred box
+	RedactBlur = "blur"
+)
+
+// Redact configuration for hiding secrets before rendering
+type Redact struct {
+	Enabled bool `json:"enabled"`
+	// Detectors lists the built-in detectors to run, all of them when empty
+	Detectors []string `json:"detectors"`
+	// Patterns are additional regular expressions to redact. When a pattern
+	// has a capture group only the first group is redacted.
+	Patterns []string `json:"patterns"`
+	// Mode is one of "mask", "box" or "blur"
+	Mode string `json:"mode"`
+	// Mask is the character used in mask mode
+	Mask string `json:"mask"`
+	// Color is the fill color used in box and blur mode
+	Color string `json:"color"`
+}
+
+// redactionDetectors are the built-in secret detectors. Detectors with a
+// capture group only redact the value, keeping the surrounding key visible.
+var redactionDetectors = map[string]*regexp.Regexp{
+	"aws":         regexp.MustCompile(`\b(?:AKIA|ASIA|ABIA|ACCA)[0-9A-Z]{16}\b|(?i:aws_secret_access_key)\s*[=:]\s*["']?([A-Za-z0-9/+=]{40})`),
+	"github":      regexp.MustCompile(`\b(?:ghp|gho|ghu|ghs|ghr)_[A-Za-z0-9]{36,255}\b|\bgithub_pat_[A-Za-z0-9_]{22,255}\b`),
+	"jwt":         regexp.MustCompile(`\beyJ[A-Za-z0-9_-]+\.eyJ[A-Za-z0-9_-]+\.[A-Za-z0-9_-]+`),
+	"private-key": regexp.MustCompile(`-----BEGIN [A-Z ]*PRIVATE KEY-----\r?\n((?s:.*?))(?:\r?\n-----END [A-Z ]*PRIVATE KEY-----|\z)`),
+	"password":    regexp.MustCompile(`(?i)\b(?:password|passwd|pwd|secret|token|api[_-]?key)\b\s*[=:]\s*(?:\x1b\[[0-9;]*m)*["']?([^\s"'\x1b]+)`),
+}
+
+// GetRedactionDetectors returns a sorted list of the built-in redaction detectors
+func GetRedactionDetectors() []string {
+	var names []string
+	for name := range redactionDetectors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// redactedSpan is a redacted range of cells on a single rendered line
+type redactedSpan struct {
+	line  int
+	col   int
+	width int
+}
+
+// compile returns the regular expressions for the configured detectors and patterns
+func (r Redact) compile() ([]*regexp.Regexp, error) {
+	var expressions []*regexp.Regexp
+	detectors := r.Detectors
+	if len(detectors) == 0 {
+		detectors = GetRedactionDetectors()
+	}
+	for _, name := range detectors {
+		re, ok := redactionDetectors[strings.ToLower(name)]
+		if !ok {
+			return nil, fmt.Errorf("unknown redaction detector %q", name)
+		}
+		expressions = append(expressions, re)
+	}
+	for _, pattern := range r.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern %q: %w", pattern, err)
+		}
+		expressions = append(expressions, re)
+	}
+	return expressions, nil
+}
+
+// find returns the byte ranges of the secrets in the input
+func (r Redact) find(input string) ([][2]int, error) {
+	if !r.Enabled {
+		return nil, nil
+	}
+
+	expressions, err := r.compile()
+	if err != nil {
+		return nil, err
+	}
+
+	var ranges [][2]int
+	for _, re := range expressions {
+		for _, m := range re.FindAllStringSubmatchIndex(input, -1) {
+			start, end := m[0], m[1]
+			if len(m) >= 4 && m[2] >= 0 {
+				start, end = m[2], m[3]
+			}
+			if start < end {
+				ranges = append(ranges, [2]int{start, end})
+			}
+		}
+	}
+	return ranges, nil
+}
+
+// apply hides the byte ranges of the input. In mask mode the secrets are
+// replaced with mask characters; in box and blur mode they are replaced with
+// spaces and the returned spans describe where boxes have to be drawn.
+func (r Redact) apply(input string, ranges [][2]int) (string, []redactedSpan) {
+	if len(ranges) == 0 {
+		return input, nil
+	}
+	ranges = mergeRanges(ranges)
+
+	mask := r.Mask
+	if r.Mode == RedactBox || r.Mode == RedactBlur {
+		mask = " "
+	} else if mask == "" {
+		mask = "*"
+	}
+
+	var spans []redactedSpan
+	var b strings.Builder
+	last := 0
+	for _, rg := range ranges {
+		b.WriteString(input[last:rg[0]])
+		if r.Mode == RedactBox || r.Mode == RedactBlur {
+			spans = append(spans, spansForRange(input, rg[0], rg[1])...)
+		}
+		b.WriteString(maskText(input[rg[0]:rg[1]], mask))
+		last = rg[1]
+	}
+	b.WriteString(input[last:])
+
+	return b.String(), spans
+}
+
+// mergeRanges sorts byte ranges and merges overlapping ones
+func mergeRanges(ranges [][2]int) [][2]int {
+	sort.Slice(ranges, func(i, j int) bool { return ranges[i][0] < ranges[j][0] })
+	merged := [][2]int{ranges[0]}
+	for _, rg := range ranges[1:] {
+		current := &merged[len(merged)-1]
+		if rg[0] <= current[1] {
+			if rg[1] > current[1] {
+				current[1] = rg[1]
+			}
+			continue
+		}
+		merged = append(merged, rg)
+	}
+	return merged
+}
+
+// maskText replaces every visible cell of text with the mask character,
+// keeping newlines and escape sequences intact
+func maskText(text, mask string) string {
+	var b strings.Builder
+	var state byte
+	for len(text) > 0 {
+		seq, width, n, newState := ansi.DecodeSequence(text, state, nil)
+		if width > 0 {
+			b.WriteString(strings.Repeat(mask, width))
+		} else {
+			b.WriteString(seq)
+		}
+		state = newState
+		text = text[n:]
+	}
+	return b.String()
+}
+
+// spansForRange converts a byte range of input into per-line cell spans
+func spansForRange(input string, start, end int) []redactedSpan {
+	var spans []redactedSpan
+	line := strings.Count(input[:start], "\n")
+	lineStart := strings.LastIndex(input[:start], "\n") + 1
+	for _, part := range strings.Split(input[start:end], "\n") {
+		col := textWidth(input[lineStart:start])
+		width := textWidth(part)
+		if width > 0 {
+			spans = append(spans, redactedSpan{line: line, col: col, width: width})
+		}
+		start += len(part) + 1
+		lineStart = start
+		line++
+	}
+	return spans
+}
+
+// textWidth returns the number of cells text occupies once rendered
+func textWidth(text string) int {
+	return ansi.StringWidth(strings.ReplaceAll(text, "\t", "    "))
+}
+
+// drawRedactions draws boxes over the redacted spans into the text group
+func drawRedactions(image, group *etree.Element, spans []redactedSpan, config *Config, layout cellLayout) {
+	color := config.Redact.Color
+	if color == "" {
+		color = "#000000"
+	}
+
+	if config.Redact.Mode == RedactBlur {
+		svg.AddBlur(image, "redactBlur", config.Font.Size/4)
+	}
+
+	for _, span := range spans {
+		rect := svg.CreateRect(
+			layout.cellX(span.col),
+			layout.cellY(span.line),
+			float64(span.width)*layout.cellWidth,
+			layout.lineHeight,
+			parseColor(color),
+		)
+		if config.Redact.Mode == RedactBlur {
+			rect.CreateAttr("filter", "url(#redactBlur)")
+		} else {
+			svg.AddCornerRadius(rect, config.Font.Size/6)
+		}
+		group.AddChild(rect)
+	}
+}
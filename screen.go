@@ -0,0 +1,531 @@
+package freezelib
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/cellbuf"
+	"github.com/mattn/go-runewidth"
+)
+
+// tabWidth is the distance between terminal tab stops
+const tabWidth = 8
+
+// maxScreenSize is the number of columns and rows escape sequences can move
+// the cursor and insert cells or lines into on screens without a set size,
+// so that large counts cannot exhaust memory
+const maxScreenSize = 10000
+
+// Terminal configuration for the size of the terminal emulated for ANSI
+// output
+type Terminal struct {
+	// Columns is the width of the terminal. Longer lines wrap like in a
+	// terminal; lines are unlimited when 0.
+	Columns int `json:"columns"`
+	// Rows is the height of the terminal screen. Lines scrolled off the top
+	// of the screen are kept above it as scrollback; the screen grows with
+	// the output when 0.
+	Rows int `json:"rows"`
+	// Crop renders only the last Rows lines, the screen, dropping the
+	// scrollback
+	Crop bool `json:"crop"`
+}
+
+// cell is a single terminal cell. Wide runes occupy their cell and a
+// following continuation cell of width 0.
+type cell struct {
+	cellbuf.Cell
+	// overline is the part of the style cellbuf does not track
+	overline bool
+}
+
+// blankCell is an empty cell
+var blankCell = cell{Cell: cellbuf.BlankCell}
+
+// newCell creates a cell of a rune in a style, linked to the URI of an
+// OSC 8 hyperlink when not empty
+func newCell(r rune, width int, style cellStyle, link string) cell {
+	return cell{
+		Cell:     cellbuf.Cell{Rune: r, Width: width, Style: style.Style, Link: cellbuf.Link{URL: link}},
+		overline: style.overline,
+	}
+}
+
+// style returns the style of the cell
+func (c cell) style() cellStyle {
+	return cellStyle{Style: c.Style, overline: c.overline}
+}
+
+// continuation reports whether the cell is the second half of a wide rune
+func (c cell) continuation() bool {
+	return c.Width == 0
+}
+
+// screen emulates a terminal screen, so that cursor movement and line
+// editing in captured output render as the final screen contents. The
+// screen is unbounded unless its columns or rows are set.
+type screen struct {
+	lines [][]cell
+	// top is the index of the first line of the screen, below the
+	// scrollback
+	top           int
+	columns, rows int
+	// row is the index of the cursor line in lines
+	row, col int
+	style    cellStyle
+	link     string
+	// savedRow and savedCol hold the cursor saved by DECSC or SCOSC
+	savedRow, savedCol int
+	// cursorHidden is set by DECTCEM
+	cursorHidden bool
+	parser       *ansi.Parser
+}
+
+// newScreen creates a screen of the terminal size
+func newScreen(terminal Terminal) *screen {
+	s := &screen{columns: terminal.Columns, rows: terminal.Rows}
+	s.parser = ansi.NewParser()
+	s.parser.SetHandler(ansi.Handler{
+		Print:     s.print,
+		Execute:   s.execute,
+		HandleCsi: s.csi,
+		HandleEsc: s.esc,
+		HandleOsc: s.osc,
+	})
+	return s
+}
+
+// Write runs terminal output through the screen. Escape sequences may be
+// split across writes.
+func (s *screen) Write(p []byte) (int, error) {
+	s.parser.Parse(p)
+	return len(p), nil
+}
+
+// emulate runs ANSI input through a terminal screen of the given size
+func emulate(input string, terminal Terminal) *screen {
+	s := newScreen(terminal)
+	_, _ = s.Write([]byte(input))
+	if terminal.Crop {
+		s.crop()
+	}
+	return s
+}
+
+// interpret runs ANSI input through an unbounded terminal screen
+func interpret(input string) *screen {
+	s := newScreen(Terminal{})
+	_, _ = s.Write([]byte(input))
+	return s
+}
+
+// resize changes the terminal size, scrolling the cursor line onto the
+// screen. Existing lines are not rewrapped.
+func (s *screen) resize(columns, rows int) {
+	s.columns, s.rows = columns, rows
+	if rows > 0 && s.row >= s.top+rows {
+		s.top = s.row - rows + 1
+	}
+	s.moveTo(s.row, s.col)
+}
+
+// crop drops the scrollback
+func (s *screen) crop() {
+	s.lines = s.lines[min(s.top, len(s.lines)):]
+	s.row -= s.top
+	s.savedRow = clamp(s.savedRow-s.top, 0, s.savedRow)
+	s.top = 0
+}
+
+// line returns the line at row, adding lines as needed
+func (s *screen) line(row int) []cell {
+	for len(s.lines) <= row {
+		s.lines = append(s.lines, nil)
+	}
+	return s.lines[row]
+}
+
+// setCell writes a cell, padding the line with blank cells and clearing
+// wide runes that are partly overwritten
+func (s *screen) setCell(row, col int, c cell) {
+	line := s.line(row)
+	for len(line) <= col {
+		line = append(line, blankCell)
+	}
+	if line[col].continuation() && col > 0 {
+		line[col-1] = blankCell
+	}
+	if col+1 < len(line) && line[col+1].continuation() {
+		line[col+1] = blankCell
+	}
+	line[col] = c
+	s.lines[row] = line
+}
+
+// print writes a rune at the cursor and advances it, wrapping at the last
+// column
+func (s *screen) print(r rune) {
+	width := runewidth.RuneWidth(r)
+	if width == 0 {
+		s.combine(r)
+		return
+	}
+	if s.columns > 0 && s.col+width > s.columns {
+		s.col = 0
+		s.lineFeed()
+	}
+	s.setCell(s.row, s.col, newCell(r, width, s.style, s.link))
+	if width > 1 {
+		s.setCell(s.row, s.col+1, newCell(0, 0, s.style, s.link))
+	}
+	s.col += width
+}
+
+// lineFeed moves the cursor down a line, scrolling the screen when the
+// cursor is on its last line
+func (s *screen) lineFeed() {
+	s.row++
+	if s.rows > 0 && s.row >= s.top+s.rows {
+		s.top = s.row - s.rows + 1
+	}
+}
+
+// moveTo moves the cursor, keeping it on the screen
+func (s *screen) moveTo(row, col int) {
+	row = clamp(row, s.top, s.bottom()-1)
+	s.row, s.col = row, clamp(col, 0, s.right(row)-1)
+}
+
+// bottom returns the index of the line below the screen. Screens without
+// a set number of rows end below their last line or maxScreenSize lines
+// below the top, whichever is lower.
+func (s *screen) bottom() int {
+	if s.rows > 0 {
+		return s.top + s.rows
+	}
+	if len(s.lines) > s.top+maxScreenSize {
+		return len(s.lines)
+	}
+	return s.top + maxScreenSize
+}
+
+// right returns the number of columns of a line. Lines of screens without
+// a set number of columns end after their last cell or at maxScreenSize,
+// whichever is further right.
+func (s *screen) right(row int) int {
+	if s.columns > 0 {
+		return s.columns
+	}
+	if row < len(s.lines) && len(s.lines[row]) > maxScreenSize {
+		return len(s.lines[row])
+	}
+	return maxScreenSize
+}
+
+// combine attaches a zero-width rune to the cell before the cursor
+func (s *screen) combine(r rune) {
+	line := s.line(s.row)
+	col := min(s.col, len(line)) - 1
+	if col > 0 && line[col].continuation() {
+		col--
+	}
+	if col >= 0 && line[col].Rune != ' ' {
+		line[col].Comb = append(line[col].Comb, r)
+	}
+}
+
+// execute handles control characters
+func (s *screen) execute(code byte) {
+	switch code {
+	case ansi.LF, ansi.VT, ansi.FF:
+		// Captured output is usually written without a terminal
+		// translating LF to CR LF, so a newline also returns the cursor
+		s.lineFeed()
+		s.col = 0
+	case ansi.CR:
+		s.col = 0
+	case ansi.BS:
+		if s.col > 0 {
+			s.col--
+		}
+	case ansi.HT:
+		s.moveTo(s.row, (s.col/tabWidth+1)*tabWidth)
+	}
+}
+
+// csi handles CSI sequences
+func (s *screen) csi(cmd ansi.Cmd, params ansi.Params) {
+	if cmd.Prefix() == '?' && (cmd.Final() == 'h' || cmd.Final() == 'l') {
+		for i := range params {
+			if mode, _, _ := params.Param(i, 0); mode == 25 { // DECTCEM
+				s.cursorHidden = cmd.Final() == 'l'
+			}
+		}
+		return
+	}
+	if cmd.Prefix() != 0 || cmd.Intermediate() != 0 {
+		// ignore other private modes and extensions
+		return
+	}
+
+	n := func(i int) int {
+		if v, _, _ := params.Param(i, 1); v > 1 {
+			return v
+		}
+		return 1
+	}
+	mode, _, _ := params.Param(0, 0)
+
+	switch cmd.Final() {
+	case 'm':
+		s.style = applySGR(s.style, params)
+	case 'A': // CUU
+		s.moveTo(s.row-n(0), s.col)
+	case 'B': // CUD
+		s.moveTo(s.row+n(0), s.col)
+	case 'C': // CUF
+		s.moveTo(s.row, s.col+n(0))
+	case 'D': // CUB
+		s.moveTo(s.row, s.col-n(0))
+	case 'E': // CNL
+		s.moveTo(s.row+n(0), 0)
+	case 'F': // CPL
+		s.moveTo(s.row-n(0), 0)
+	case 'G', '`': // CHA, HPA
+		s.moveTo(s.row, n(0)-1)
+	case 'd': // VPA
+		s.moveTo(s.top+n(0)-1, s.col)
+	case 'H', 'f': // CUP, HVP
+		s.moveTo(s.top+n(0)-1, n(1)-1)
+	case 'K': // EL
+		s.eraseLine(s.row, mode)
+	case 'J': // ED
+		s.eraseDisplay(mode)
+	case 'X': // ECH
+		for col := s.col; col < min(s.col+n(0), len(s.line(s.row))); col++ {
+			s.setCell(s.row, col, blankCell)
+		}
+	case '@': // ICH
+		line := s.line(s.row)
+		if s.col < len(line) {
+			right := s.right(s.row)
+			blanks := make([]cell, min(n(0), right-s.col))
+			for i := range blanks {
+				blanks[i] = blankCell
+			}
+			line = append(line[:s.col], append(blanks, line[s.col:]...)...)
+			if len(line) > right {
+				if line[right].continuation() {
+					line[right-1] = blankCell
+				}
+				line = line[:right]
+			}
+			s.lines[s.row] = line
+		}
+	case 'P': // DCH
+		line := s.line(s.row)
+		if s.col < len(line) {
+			s.lines[s.row] = append(line[:s.col], line[min(s.col+n(0), len(line)):]...)
+		}
+	case 'L': // IL
+		s.insertLines(s.row, n(0))
+	case 'M': // DL
+		s.deleteLines(s.row, n(0))
+	case 'S': // SU
+		s.deleteLines(s.top, n(0))
+	case 'T': // SD
+		s.insertLines(s.top, n(0))
+	case 's': // SCOSC
+		s.savedRow, s.savedCol = s.row, s.col
+	case 'u': // SCORC
+		s.moveTo(s.savedRow, s.savedCol)
+	}
+}
+
+// esc handles ESC sequences
+func (s *screen) esc(cmd ansi.Cmd) {
+	if cmd.Intermediate() != 0 {
+		// ignore character set selection
+		return
+	}
+
+	switch cmd.Final() {
+	case '7': // DECSC
+		s.savedRow, s.savedCol = s.row, s.col
+	case '8': // DECRC
+		s.moveTo(s.savedRow, s.savedCol)
+	case 'D': // IND
+		s.lineFeed()
+	case 'E': // NEL
+		s.lineFeed()
+		s.col = 0
+	case 'M': // RI
+		if s.row == s.top {
+			s.insertLines(s.top, 1)
+		} else {
+			s.row--
+		}
+	case 'c': // RIS
+		*s = screen{columns: s.columns, rows: s.rows, parser: s.parser}
+	}
+}
+
+// osc handles OSC sequences
+func (s *screen) osc(cmd int, data []byte) {
+	if uri, ok := parseHyperlink(data); cmd == 8 && ok {
+		s.link = uri
+	}
+}
+
+// eraseLine erases part of a line: from the cursor to the end (0), from
+// the start to the cursor (1) or the whole line (2)
+func (s *screen) eraseLine(row, mode int) {
+	line := s.line(row)
+	switch mode {
+	case 0:
+		if s.col < len(line) {
+			if s.col > 0 && line[s.col].continuation() {
+				line[s.col-1] = blankCell
+			}
+			s.lines[row] = line[:s.col]
+		}
+	case 1:
+		for col := 0; col <= s.col && col < len(line); col++ {
+			s.setCell(row, col, blankCell)
+		}
+	case 2:
+		s.lines[row] = nil
+	}
+}
+
+// eraseDisplay erases part of the screen: from the cursor to the end (0),
+// from the start to the cursor (1) or everything (2). Mode 3 erases the
+// scrollback.
+func (s *screen) eraseDisplay(mode int) {
+	switch mode {
+	case 0:
+		s.eraseLine(s.row, 0)
+		s.lines = s.lines[:s.row+1]
+	case 1:
+		s.line(s.row)
+		for row := s.top; row < s.row; row++ {
+			s.lines[row] = nil
+		}
+		s.eraseLine(s.row, 1)
+	case 2:
+		for row := s.top; row < len(s.lines); row++ {
+			s.lines[row] = nil
+		}
+	case 3:
+		s.crop()
+	}
+}
+
+// insertLines inserts blank lines at row, moving the lines below down and
+// off the bottom of the screen
+func (s *screen) insertLines(row, n int) {
+	s.line(row)
+	bottom := s.bottom()
+	s.lines = append(s.lines[:row], append(make([][]cell, min(n, bottom-row)), s.lines[row:]...)...)
+	if len(s.lines) > bottom {
+		s.lines = s.lines[:bottom]
+	}
+}
+
+// deleteLines deletes lines at row, moving the lines below up
+func (s *screen) deleteLines(row, n int) {
+	s.line(row)
+	s.lines = append(s.lines[:row], s.lines[min(row+n, len(s.lines)):]...)
+}
+
+// wrapCells splits a line into lines of at most columns cells, breaking
+// after the last space that fits when there is one. Wide runes are not
+// split, and no cells are dropped.
+func wrapCells(line []cell, columns int) [][]cell {
+	var lines [][]cell
+	for len(line) > columns {
+		end := columns
+		for i := columns; i > 0; i-- {
+			if line[i-1].Rune == ' ' && !line[i-1].continuation() {
+				end = i
+				break
+			}
+		}
+		if line[end].continuation() {
+			end--
+		}
+		if end == 0 {
+			// a wide rune wider than the lines
+			end = min(2, len(line))
+		}
+		lines = append(lines, line[:end])
+		line = line[end:]
+	}
+	return append(lines, line)
+}
+
+// height returns the number of rows up to the last non-empty line, the
+// cursor or the bottom of the screen, whichever is lowest
+func (s *screen) height() int {
+	last := s.row
+	if s.rows > 0 && last < s.top+s.rows-1 {
+		// show the whole screen
+		last = s.top + s.rows - 1
+	}
+	for row := len(s.lines) - 1; row > last; row-- {
+		if len(s.lines[row]) > 0 {
+			last = row
+			break
+		}
+	}
+	return last + 1
+}
+
+// width returns the number of columns of the screen, or of the longest
+// line when the screen has no fixed width
+func (s *screen) width() int {
+	width := s.columns
+	for _, line := range s.lines {
+		if len(line) > width {
+			width = len(line)
+		}
+	}
+	return width
+}
+
+// String returns the screen contents, styled with SGR sequences
+func (s *screen) String() string {
+	var b strings.Builder
+	for row := 0; row < s.height(); row++ {
+		if row > 0 {
+			b.WriteByte('\n')
+		}
+		if row >= len(s.lines) {
+			continue
+		}
+
+		style := cellStyle{}
+		link := ""
+		for _, c := range s.lines[row] {
+			if c.continuation() {
+				continue
+			}
+			if c.Link.URL != link {
+				b.WriteString(ansi.SetHyperlink(c.Link.URL))
+				link = c.Link.URL
+			}
+			if !c.style().equal(style) {
+				b.WriteString(c.style().sgr())
+				style = c.style()
+			}
+			b.WriteString(c.String())
+		}
+		if link != "" {
+			b.WriteString(ansi.ResetHyperlink())
+		}
+		if !style.empty() {
+			b.WriteString(ansi.ResetStyle)
+		}
+	}
+	return b.String()
+}
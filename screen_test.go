@@ -0,0 +1,180 @@
+package freezelib
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestEmulateTerminal(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Carriage return", "Downloading 10%\rDownloading 100%\nDone\n", "Downloading 100%\nDone\n"},
+		{"Cursor up and erase line", "step 1\nworking...\x1b[1A\x1b[2K\rstep 1 done\n", "step 1 done\nworking..."},
+		{"Cursor back", "abc\x1b[2Dx", "axc"},
+		{"Cursor position", "aaa\nbbb\x1b[1;2Hx", "axa\nbbb"},
+		{"Erase display", "old\x1b[H\x1b[2Jnew", "new"},
+		{"Erase to end of line", "hello world\x1b[6D\x1b[K", "hello"},
+		{"Scroll up", "one\ntwo\x1b[1S", "two\n"},
+		{"Save and restore cursor", "a\x1b7bc\x1b8X", "aXc"},
+		{"Styles", "\x1b[31mred\x1b[0m \x1b[1mbold\nnext", "\x1b[0;31mred\x1b[0m \x1b[0;1mbold\x1b[m\n\x1b[0;1mnext\x1b[m"},
+		{"True color sub-parameters", "\x1b[38:2::255:0:0mx", "\x1b[0;38;2;255;0;0mx\x1b[m"},
+		{"Wide characters", "日本語\x1b[3D\x1b[Kx", "日 x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := emulate(tt.input, Terminal{}).String(); got != tt.expected {
+				t.Errorf("emulate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestANSIProgressOutput(t *testing.T) {
+	svgData, err := NewGenerator(DefaultConfig()).GenerateFromANSI("Pulling 10%\rPulling 55%\rPulling 100%\n")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+	if strings.Contains(string(svgData), "10%") || !strings.Contains(string(svgData), "Pulling 100%") {
+		t.Errorf("SVG should only contain the final progress line")
+	}
+}
+
+func TestANSIGrid(t *testing.T) {
+	svgData, err := NewGenerator(DefaultConfig()).GenerateFromANSI("日本x\x1b[41m \x1b[0m e\u0301")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+	for _, expected := range []string{
+		`x="20.00px">日</tspan>`,
+		`x="36.80px">本</tspan>`,
+		`x="53.60px">x</tspan>`,
+		`<rect x="62.00" y="`,
+		"x=\"70.40px\"> e\u0301</tspan>",
+	} {
+		if !strings.Contains(string(svgData), expected) {
+			t.Errorf("SVG should contain %s", expected)
+		}
+	}
+}
+
+func TestANSILineSelection(t *testing.T) {
+	tests := []struct {
+		name       string
+		input      string
+		expected   []string
+		unexpected []string
+	}{
+		{"Carried style", "\x1b[31mred\nstill red\x1b[0m\nplain", []string{`fill="#FF0000">still red`}, []string{"plain"}},
+		{"Combined style", "\x1b[31mred\x1b[1m\nbold red\nnext", []string{`fill="#FF0000" font-weight="bold">bold red`}, nil},
+		{"Reset style", "\x1b[31mred\x1b[0m\nplain\nnext", []string{">plain"}, []string{"#FF0000"}},
+		{"Carried link", "\x1b]8;;https://example.com\x07link\nstill linked\nnext", []string{`href="https://example.com"`}, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig().SetLines(2, 2)
+			config.ShowLineNumbers = true
+			svgData, err := NewGenerator(config).GenerateFromANSI(tt.input)
+			if err != nil {
+				t.Fatalf("GenerateFromANSI failed: %v", err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(svgData), expected) {
+					t.Errorf("SVG should contain %s", expected)
+				}
+			}
+			for _, unexpected := range tt.unexpected {
+				if strings.Contains(string(svgData), unexpected) {
+					t.Errorf("SVG should not contain %s", unexpected)
+				}
+			}
+		})
+	}
+}
+
+func TestTerminalSize(t *testing.T) {
+	tests := []struct {
+		name     string
+		terminal Terminal
+		input    string
+		expected string
+	}{
+		{"Wrap", Terminal{Columns: 4}, "abcdefghij", "abcd\nefgh\nij"},
+		{"Wrap wide characters", Terminal{Columns: 5}, "日本語", "日本\n語"},
+		{"Exact width", Terminal{Columns: 3}, "abc\ndef", "abc\ndef"},
+		{"Clamp cursor", Terminal{Columns: 4, Rows: 2}, "ab\x1b[10Cx\x1b[10By", "ab x\n   y"},
+		{"Scrollback", Terminal{Rows: 2}, "1\n2\n3\x1b[Hx", "1\nx\n3"},
+		{"Crop", Terminal{Rows: 2, Crop: true}, "1\n2\n3\x1b[Hx", "x\n3"},
+		{"Fill screen", Terminal{Rows: 3}, "1", "1\n\n"},
+		{"Scroll region", Terminal{Rows: 2}, "1\n2\n3\x1b[1S", "1\n3\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := emulate(tt.input, tt.terminal).String(); got != tt.expected {
+				t.Errorf("emulate() = %q, want %q", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestLargeEscapeCounts(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+	}{
+		{"Insert characters", "abc\x1b[2D\x1b[99999999@"},
+		{"Insert lines", "abc\x1b[99999999L"},
+		{"Scroll down", "abc\x1b[99999999T"},
+		{"Cursor forward", "\x1b[999999999Cq"},
+		{"Cursor down", "\x1b[999999999Bq"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewGenerator(DefaultConfig()).GenerateFromANSI(tt.input); err != nil {
+				t.Errorf("GenerateFromANSI failed: %v", err)
+			}
+
+			data, _ := json.Marshal(tt.input)
+			cast := `{"version": 2, "width": 80, "height": 24}` + "\n[0.1, \"o\", " + string(data) + "]\n"
+			if _, err := NewGenerator(DefaultConfig()).GenerateFromCast(strings.NewReader(cast), 0); err != nil {
+				t.Errorf("GenerateFromCast failed: %v", err)
+			}
+		})
+	}
+}
+
+func TestScreenWrite(t *testing.T) {
+	s := newScreen(Terminal{})
+	for _, chunk := range []string{"a\x1b[3", "1mb\x1b]8;;https://exa", "mple.com\x07c"} {
+		if _, err := s.Write([]byte(chunk)); err != nil {
+			t.Fatalf("Write() failed: %v", err)
+		}
+	}
+	if expected := "a\x1b[0;31mb\x1b]8;;https://example.com\x07c\x1b]8;;\x07\x1b[m"; s.String() != expected {
+		t.Errorf("String() = %q, want %q", s.String(), expected)
+	}
+}
+
+func TestANSITerminalSize(t *testing.T) {
+	config := DefaultConfig().SetTerminal(Terminal{Columns: 20, Rows: 5})
+	svgData, err := NewGenerator(config).GenerateFromANSI("$ ls")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+	if !strings.Contains(string(svgData), `width="216.40" height="127.36"`) {
+		t.Errorf("SVG should be sized to the terminal grid")
+	}
+
+	// The window controls must not push the last row out of view
+	svgData, err = NewGenerator(DefaultConfig().SetWindow(true)).GenerateFromANSI("one\ntwo\nthree")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+	if !strings.Contains(string(svgData), ">three</tspan>") {
+		t.Errorf("SVG should contain the last line")
+	}
+}
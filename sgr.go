@@ -0,0 +1,119 @@
+package freezelib
+
+import (
+	"fmt"
+	"image/color"
+	"strings"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/cellbuf"
+)
+
+// cellStyle is the SGR (Select Graphic Rendition) state of a terminal cell:
+// a cellbuf style, plus overline which cellbuf does not track
+type cellStyle struct {
+	cellbuf.Style
+	overline bool
+}
+
+// has reports whether the style has all the attributes
+func (s cellStyle) has(attrs cellbuf.AttrMask) bool {
+	return s.Attrs.Contains(attrs)
+}
+
+// equal reports whether two styles are the same
+func (s cellStyle) equal(o cellStyle) bool {
+	return s.Style.Equal(&o.Style) && s.overline == o.overline
+}
+
+// empty reports whether the style is the default style
+func (s cellStyle) empty() bool {
+	return s.Style.Empty() && !s.overline
+}
+
+// sgr returns the SGR sequence selecting the style after a reset
+func (s cellStyle) sgr() string {
+	params := []string{"0"}
+	if !s.Style.Empty() {
+		params = append(params, strings.TrimSuffix(strings.TrimPrefix(s.Sequence(), "\x1b["), "m"))
+	}
+	if s.overline {
+		params = append(params, "53")
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// colors returns the foreground and background colors of the style, with
+// inverse video applied. Default colors are empty unless inverse video
+// swaps them with the other color.
+func (s cellStyle) colors(tp TerminalPalette) (string, string) {
+	fg := paletteColor(s.Fg, tp)
+	bg := paletteColor(s.Bg, tp)
+	if !s.has(cellbuf.ReverseAttr) {
+		return fg, bg
+	}
+	if fg == "" {
+		fg = tp.Foreground
+	}
+	if bg == "" {
+		bg = tp.Background
+	}
+	return bg, fg
+}
+
+// paletteColor converts a terminal color to a color, looking up indexed
+// colors in the palette. The default and transparent colors are empty.
+func paletteColor(c ansi.Color, tp TerminalPalette) string {
+	switch c := c.(type) {
+	case nil:
+		return ""
+	case ansi.BasicColor:
+		return tp.color(int(c))
+	case ansi.ExtendedColor:
+		return tp.color(int(c))
+	default:
+		rgba := color.RGBAModel.Convert(c).(color.RGBA)
+		if rgba.A == 0 {
+			return ""
+		}
+		return fmt.Sprintf("rgb(%d,%d,%d)", rgba.R, rgba.G, rgba.B)
+	}
+}
+
+// applySGR applies SGR parameters to a style. cellbuf reads the
+// parameters, except for the double underline (21) and overline (53, 55)
+// ones it does not know.
+func applySGR(style cellStyle, params ansi.Params) cellStyle {
+	if len(params) == 0 {
+		return cellStyle{}
+	}
+
+	for i := 0; i < len(params); i++ {
+		// n is the number of parameters of the attribute, with its
+		// sub-parameters or color
+		n := 1
+		switch v := params[i].Param(0); v {
+		case 0:
+			style = cellStyle{}
+			continue
+		case 21:
+			style.UlStyle = cellbuf.DoubleUnderline
+			continue
+		case 53, 55:
+			style.overline = v == 53
+			continue
+		case 4:
+			if params[i].HasMore() && i+1 < len(params) {
+				n = 2
+			}
+		case 38, 48, 58:
+			var c color.Color
+			if read := ansi.ReadStyleColor(params[i:], &c); read > 0 {
+				n = read
+			}
+		}
+		cellbuf.ReadStyle(params[i:i+n], &style.Style)
+		i += n - 1
+	}
+	return style
+}
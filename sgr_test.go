@@ -0,0 +1,83 @@
+package freezelib
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/x/ansi"
+	"github.com/charmbracelet/x/cellbuf"
+)
+
+func TestApplySGR(t *testing.T) {
+	tests := []struct {
+		name     string
+		params   []int
+		initial  cellStyle
+		expected cellStyle
+	}{
+		{"Combined decorations", []int{4, 9, 53}, cellStyle{}, cellStyle{Style: cellbuf.Style{Attrs: cellbuf.StrikethroughAttr, UlStyle: cellbuf.SingleUnderline}, overline: true}},
+		{"Bold and faint off", []int{22}, cellStyle{Style: cellbuf.Style{Attrs: cellbuf.BoldAttr | cellbuf.FaintAttr | cellbuf.ItalicAttr}}, cellStyle{Style: cellbuf.Style{Attrs: cellbuf.ItalicAttr}}},
+		{"Attributes off", []int{23, 24, 25, 27, 28, 29, 55}, cellStyle{Style: cellbuf.Style{Attrs: cellbuf.ItalicAttr | cellbuf.SlowBlinkAttr | cellbuf.ReverseAttr | cellbuf.ConcealAttr | cellbuf.StrikethroughAttr, UlStyle: cellbuf.CurlyUnderline}, overline: true}, cellStyle{}},
+		{"Default colors", []int{39, 49}, cellStyle{Style: cellbuf.Style{Fg: ansi.Red, Bg: ansi.Blue, Attrs: cellbuf.BoldAttr}}, cellStyle{Style: cellbuf.Style{Attrs: cellbuf.BoldAttr}}},
+		{"Extended colors", []int{38, 5, 208, 48, 2, 1, 2, 3}, cellStyle{}, cellStyle{Style: cellbuf.Style{Fg: ansi.ExtendedColor(208), Bg: color.RGBA{1, 2, 3, 255}}}},
+		{"Double underline", []int{21}, cellStyle{}, cellStyle{Style: cellbuf.Style{UlStyle: cellbuf.DoubleUnderline}}},
+		{"Double underline off", []int{21, 0}, cellStyle{}, cellStyle{}},
+		{"Underline color", []int{58, 5, 9, 59, 58, 2, 1, 2, 3}, cellStyle{}, cellStyle{Style: cellbuf.Style{Ul: color.RGBA{1, 2, 3, 255}}}},
+		{"Overline in color", []int{38, 5, 53}, cellStyle{}, cellStyle{Style: cellbuf.Style{Fg: ansi.ExtendedColor(53)}}},
+		{"Reset", []int{0, 7}, cellStyle{Style: cellbuf.Style{Fg: ansi.Red, Attrs: cellbuf.BoldAttr}}, cellStyle{Style: cellbuf.Style{Attrs: cellbuf.ReverseAttr}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := applySGR(tt.initial, ansi.ToParams(tt.params)); !got.equal(tt.expected) {
+				t.Errorf("applySGR() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestANSIStyles(t *testing.T) {
+	config := DefaultConfig().SetTerminalPalette(TerminalPalette{Foreground: "#eeeeee", Background: "#111111"})
+	svgData, err := NewGenerator(config).GenerateFromANSI("\x1b[7minv\x1b[27m \x1b[2mfaint\x1b[22m \x1b[8mhidden\x1b[0m \x1b[1;3mbi\x1b[0m")
+	if err != nil {
+		t.Fatalf("GenerateFromANSI failed: %v", err)
+	}
+	for _, expected := range []string{
+		`<tspan xml:space="preserve" x="20.00px" fill="#111111">inv</tspan>`,
+		`fill="#eeeeee"/>`,
+		`fill-opacity="0.5">faint`,
+		`fill-opacity="0">hidden`,
+		`font-weight="bold" font-style="italic">bi`,
+	} {
+		if !strings.Contains(string(svgData), expected) {
+			t.Errorf("SVG should contain %s", expected)
+		}
+	}
+}
+
+func TestANSIUnderlineStyles(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		expected string
+	}{
+		{"Double", "\x1b[4:2mx", `<rect x="20.00" y="39.32"`},
+		{"Curly", "\x1b[4:3mx", `<path d="M20.00 38.97 q`},
+		{"Dotted", "\x1b[4:4mx", `stroke-dasharray="1.40"`},
+		{"Dashed", "\x1b[4:5mx", `stroke-dasharray="4.20 2.10"`},
+		{"Colored", "\x1b[4;58;2;255;0;0mx", `fill="rgb(255,0,0)"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			svgData, err := NewGenerator(DefaultConfig()).GenerateFromANSI(tt.input)
+			if err != nil {
+				t.Fatalf("GenerateFromANSI failed: %v", err)
+			}
+			if !strings.Contains(string(svgData), tt.expected) {
+				t.Errorf("SVG should contain %s", tt.expected)
+			}
+		})
+	}
+}
@@ -39,6 +39,32 @@ func AddShadow(element *etree.Element, id string, x, y, blur float64) {
 	element.AddChild(defs)
 }
 
+// AddBlur adds a definition of a gaussian blur filter to the <defs> with the given id.
+func AddBlur(element *etree.Element, id string, blur float64) {
+	f := etree.NewElement("filter")
+	f.CreateAttr("id", id)
+	f.CreateAttr("x", "-50%")
+	f.CreateAttr("y", "-50%")
+	f.CreateAttr("width", "200%")
+	f.CreateAttr("height", "200%")
+
+	b := etree.NewElement("feGaussianBlur")
+	b.CreateAttr("in", "SourceGraphic")
+	b.CreateAttr("stdDeviation", fmt.Sprintf("%.2f", blur))
+	f.AddChild(b)
+
+	defs := etree.NewElement("defs")
+	defs.AddChild(f)
+	element.AddChild(defs)
+}
+
+// AddStyleSheet adds a <style> element with the given CSS at the start of the element.
+func AddStyleSheet(element *etree.Element, css string) {
+	style := etree.NewElement("style")
+	style.SetCData(css)
+	element.InsertChildAt(0, style)
+}
+
 // AddClipPath adds a definition of a clip path to the <defs> with the given id.
 func AddClipPath(element *etree.Element, id string, x, y, w, h float64) {
 	p := etree.NewElement("clipPath")
@@ -149,6 +175,42 @@ func CreateRect(x, y, width, height float64, fill string) *etree.Element {
 	return rect
 }
 
+// CreateLine creates a horizontal line element. A dash pattern such as
+// "2 2" draws a dashed or dotted line.
+func CreateLine(x, y, width, strokeWidth float64, stroke, dashes string) *etree.Element {
+	line := etree.NewElement("line")
+	line.CreateAttr("x1", fmt.Sprintf("%.2f", x))
+	line.CreateAttr("y1", fmt.Sprintf("%.2f", y))
+	line.CreateAttr("x2", fmt.Sprintf("%.2f", x+width))
+	line.CreateAttr("y2", fmt.Sprintf("%.2f", y))
+	line.CreateAttr("stroke", stroke)
+	line.CreateAttr("stroke-width", fmt.Sprintf("%.2f", strokeWidth))
+	if dashes != "" {
+		line.CreateAttr("stroke-dasharray", dashes)
+	}
+	return line
+}
+
+// CreateWave creates a horizontal wavy line element centered on y
+func CreateWave(x, y, width, amplitude, wavelength, strokeWidth float64, stroke string) *etree.Element {
+	var d strings.Builder
+	fmt.Fprintf(&d, "M%.2f %.2f", x, y)
+	for i := 0; float64(i)*wavelength/2 < width; i++ {
+		dy := amplitude
+		if i%2 == 0 {
+			dy = -amplitude
+		}
+		fmt.Fprintf(&d, " q%.2f %.2f %.2f 0", wavelength/4, 2*dy, wavelength/2)
+	}
+
+	wave := etree.NewElement("path")
+	wave.CreateAttr("d", d.String())
+	wave.CreateAttr("fill", "none")
+	wave.CreateAttr("stroke", stroke)
+	wave.CreateAttr("stroke-width", fmt.Sprintf("%.2f", strokeWidth))
+	return wave
+}
+
 // CreateText creates a text element
 func CreateText(x, y float64, content string) *etree.Element {
 	text := etree.NewElement("text")
@@ -0,0 +1,145 @@
+package freezelib
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// TokenStyle overrides the style of a single token type. Unset fields keep
+// the value of the selected theme.
+type TokenStyle struct {
+	Color      string `json:"color,omitempty"`
+	Background string `json:"background,omitempty"`
+	Bold       *bool  `json:"bold,omitempty"`
+	Italic     *bool  `json:"italic,omitempty"`
+	Underline  *bool  `json:"underline,omitempty"`
+}
+
+// resolveStyle returns the chroma style for the configured theme with the
+// background and theme overrides applied. The global registry is never modified.
+func (c *Config) resolveStyle() (*chroma.Style, error) {
+	style, ok := getCustomTheme(c.Theme)
+	if !ok {
+		style, ok = styles.Registry[strings.ToLower(c.Theme)]
+	}
+	if !ok || style == nil {
+		style = styles.Get("github") // fallback to github style
+	}
+
+	if style.Has(chroma.Background) && len(c.ThemeOverrides) == 0 {
+		return style, nil
+	}
+
+	builder := style.Builder()
+
+	// Add background color to style if not present
+	if !style.Has(chroma.Background) {
+		builder.Add(chroma.Background, "bg:"+c.Background)
+	}
+
+	overrides, err := c.themeOverrides()
+	if err != nil {
+		return nil, err
+	}
+	for _, override := range overrides {
+		// Apply to the token type itself and to every more specific type
+		// the theme defines, so overriding Comment also affects CommentSingle
+		for _, tokenType := range chroma.TokenTypeValues() {
+			if tokenType != override.tokenType && !(style.Has(tokenType) && isSubTokenType(tokenType, override.tokenType)) {
+				continue
+			}
+			entry, err := override.style.apply(builder.Get(tokenType))
+			if err != nil {
+				return nil, fmt.Errorf("invalid theme override for %s: %w", override.tokenType, err)
+			}
+			builder.AddEntry(tokenType, entry)
+		}
+	}
+
+	style, err = builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("could not build style: %w", err)
+	}
+	return style, nil
+}
+
+// tokenOverride is a theme override resolved to its chroma token type
+type tokenOverride struct {
+	tokenType chroma.TokenType
+	style     TokenStyle
+}
+
+// themeOverrides resolves the configured overrides, ordered from the most
+// general to the most specific token type
+func (c *Config) themeOverrides() ([]tokenOverride, error) {
+	var overrides []tokenOverride
+	for name, style := range c.ThemeOverrides {
+		tokenType, err := parseTokenType(name)
+		if err != nil {
+			return nil, err
+		}
+		overrides = append(overrides, tokenOverride{tokenType: tokenType, style: style})
+	}
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].tokenType < overrides[j].tokenType
+	})
+	return overrides, nil
+}
+
+// apply merges the override onto a style entry
+func (ts TokenStyle) apply(entry chroma.StyleEntry) (chroma.StyleEntry, error) {
+	if ts.Color != "" {
+		entry.Colour = chroma.ParseColour(ts.Color)
+		if !entry.Colour.IsSet() {
+			return entry, fmt.Errorf("invalid color %q", ts.Color)
+		}
+	}
+	if ts.Background != "" {
+		entry.Background = chroma.ParseColour(ts.Background)
+		if !entry.Background.IsSet() {
+			return entry, fmt.Errorf("invalid background %q", ts.Background)
+		}
+	}
+	entry.Bold = trilean(ts.Bold, entry.Bold)
+	entry.Italic = trilean(ts.Italic, entry.Italic)
+	entry.Underline = trilean(ts.Underline, entry.Underline)
+	return entry, nil
+}
+
+// trilean converts an optional bool to a chroma trilean
+func trilean(value *bool, fallback chroma.Trilean) chroma.Trilean {
+	switch {
+	case value == nil:
+		return fallback
+	case *value:
+		return chroma.Yes
+	default:
+		return chroma.No
+	}
+}
+
+// isSubTokenType checks if tokenType belongs to the category or sub-category parent
+func isSubTokenType(tokenType, parent chroma.TokenType) bool {
+	return tokenType != parent && (tokenType.Category() == parent || tokenType.SubCategory() == parent)
+}
+
+// parseTokenType converts a token type name such as "Comment",
+// "NameFunction" or "name.function" to a chroma token type
+func parseTokenType(name string) (chroma.TokenType, error) {
+	normalized := normalizeTokenTypeName(name)
+	for _, tokenType := range chroma.TokenTypeValues() {
+		if normalizeTokenTypeName(tokenType.String()) == normalized {
+			return tokenType, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown token type %q", name)
+}
+
+// normalizeTokenTypeName lowercases a token type name and removes separators
+func normalizeTokenTypeName(name string) string {
+	return strings.ToLower(strings.NewReplacer(".", "", "_", "", "-", "", " ", "").Replace(name))
+}
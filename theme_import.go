@@ -0,0 +1,636 @@
+package freezelib
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/alecthomas/chroma/v2"
+)
+
+// customThemes holds themes registered with RegisterTheme. They are looked
+// up before chroma's global styles.Registry.
+var (
+	customThemes   = map[string]*chroma.Style{}
+	customThemesMu sync.RWMutex
+)
+
+// RegisterTheme makes a style available via Config.Theme under its name.
+// The style is kept in freezelib's own theme table; use chroma's
+// styles.Register to add it to the global chroma registry as well.
+func RegisterTheme(style *chroma.Style) error {
+	if style == nil || style.Name == "" {
+		return errors.New("theme must have a name")
+	}
+	customThemesMu.Lock()
+	defer customThemesMu.Unlock()
+	customThemes[strings.ToLower(style.Name)] = style
+	return nil
+}
+
+// UnregisterTheme removes a theme registered with RegisterTheme
+func UnregisterTheme(name string) {
+	customThemesMu.Lock()
+	defer customThemesMu.Unlock()
+	delete(customThemes, strings.ToLower(name))
+}
+
+// getCustomTheme returns a theme registered with RegisterTheme
+func getCustomTheme(name string) (*chroma.Style, bool) {
+	customThemesMu.RLock()
+	defer customThemesMu.RUnlock()
+	style, ok := customThemes[strings.ToLower(name)]
+	return style, ok
+}
+
+// customThemeNames returns the names of all themes registered with RegisterTheme
+func customThemeNames() []string {
+	customThemesMu.RLock()
+	defer customThemesMu.RUnlock()
+	var names []string
+	for name := range customThemes {
+		names = append(names, name)
+	}
+	return names
+}
+
+// LoadThemeFile loads a color theme from a file, choosing the format from the
+// extension: VS Code themes (.json), TextMate themes (.tmTheme), Sublime
+// color schemes (.sublime-color-scheme) and chroma styles (.xml).
+// The theme name defaults to the file name when the file does not define one.
+func LoadThemeFile(filename string) (*chroma.Style, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open theme: %w", err)
+	}
+	defer f.Close()
+
+	var style *chroma.Style
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".json", ".jsonc":
+		style, err = LoadVSCodeTheme(f)
+	case ".tmtheme", ".plist":
+		style, err = LoadTextMateTheme(f)
+	case ".sublime-color-scheme":
+		style, err = LoadSublimeColorScheme(f)
+	case ".xml":
+		style, err = LoadChromaStyle(f)
+	default:
+		return nil, fmt.Errorf("unsupported theme format %q", filepath.Ext(filename))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if style.Name == "" || style.Name == defaultThemeName {
+		name := strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+		style, err = style.Builder().Build()
+		if err != nil {
+			return nil, err
+		}
+		style.Name = name
+	}
+	return style, nil
+}
+
+// defaultThemeName is used for imported themes without a name
+const defaultThemeName = "custom"
+
+// LoadChromaStyle loads a chroma XML style definition
+func LoadChromaStyle(r io.Reader) (*chroma.Style, error) {
+	style, err := chroma.NewXMLStyle(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse chroma style: %w", err)
+	}
+	return style, nil
+}
+
+// LoadVSCodeTheme loads a VS Code color theme (JSON with comments)
+func LoadVSCodeTheme(r io.Reader) (*chroma.Style, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme: %w", err)
+	}
+
+	var theme struct {
+		Name        string            `json:"name"`
+		Colors      map[string]string `json:"colors"`
+		TokenColors []struct {
+			Scope    json.RawMessage `json:"scope"`
+			Settings struct {
+				Foreground string  `json:"foreground"`
+				Background string  `json:"background"`
+				FontStyle  *string `json:"fontStyle"`
+			} `json:"settings"`
+		} `json:"tokenColors"`
+	}
+	if err := json.Unmarshal(stripJSONComments(data), &theme); err != nil {
+		return nil, fmt.Errorf("failed to parse VS Code theme: %w", err)
+	}
+
+	t := importedTheme{
+		name:          theme.Name,
+		background:    theme.Colors["editor.background"],
+		foreground:    theme.Colors["editor.foreground"],
+		lineNumbers:   theme.Colors["editorLineNumber.foreground"],
+		lineHighlight: theme.Colors["editor.lineHighlightBackground"],
+	}
+	for _, tc := range theme.TokenColors {
+		if len(tc.Scope) == 0 {
+			// A rule without scope sets the global colors
+			t.foreground = firstNonEmpty(t.foreground, tc.Settings.Foreground)
+			t.background = firstNonEmpty(t.background, tc.Settings.Background)
+			continue
+		}
+		var scopes []string
+		if err := json.Unmarshal(tc.Scope, &scopes); err != nil {
+			var scope string
+			if err := json.Unmarshal(tc.Scope, &scope); err != nil {
+				return nil, fmt.Errorf("failed to parse VS Code theme: invalid scope %s", tc.Scope)
+			}
+			scopes = []string{scope}
+		}
+		rule := scopeRule{
+			selectors:  splitSelectors(scopes),
+			foreground: tc.Settings.Foreground,
+			background: tc.Settings.Background,
+		}
+		if tc.Settings.FontStyle != nil {
+			rule.fontStyle = *tc.Settings.FontStyle
+			rule.hasStyle = true
+		}
+		t.rules = append(t.rules, rule)
+	}
+	return t.build()
+}
+
+// LoadTextMateTheme loads a TextMate (.tmTheme) color theme, as also used by Sublime Text
+func LoadTextMateTheme(r io.Reader) (*chroma.Style, error) {
+	root, err := decodePlist(xml.NewDecoder(r))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse TextMate theme: %w", err)
+	}
+	dict, ok := root.(map[string]any)
+	if !ok {
+		return nil, errors.New("failed to parse TextMate theme: root is not a dictionary")
+	}
+
+	t := importedTheme{}
+	t.name, _ = dict["name"].(string)
+	settings, _ := dict["settings"].([]any)
+	for _, item := range settings {
+		entry, ok := item.(map[string]any)
+		if !ok {
+			continue
+		}
+		values, _ := entry["settings"].(map[string]any)
+		get := func(key string) string {
+			value, _ := values[key].(string)
+			return value
+		}
+		scope, hasScope := entry["scope"].(string)
+		if !hasScope {
+			// The first entry without scope holds the global settings
+			t.background = firstNonEmpty(t.background, get("background"))
+			t.foreground = firstNonEmpty(t.foreground, get("foreground"))
+			t.lineNumbers = firstNonEmpty(t.lineNumbers, get("gutterForeground"))
+			t.lineHighlight = firstNonEmpty(t.lineHighlight, get("lineHighlight"))
+			continue
+		}
+		fontStyle, hasStyle := values["fontStyle"].(string)
+		t.rules = append(t.rules, scopeRule{
+			selectors:  splitSelectors([]string{scope}),
+			foreground: get("foreground"),
+			background: get("background"),
+			fontStyle:  fontStyle,
+			hasStyle:   hasStyle,
+		})
+	}
+	return t.build()
+}
+
+// LoadSublimeColorScheme loads a Sublime Text color scheme (.sublime-color-scheme)
+func LoadSublimeColorScheme(r io.Reader) (*chroma.Style, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read theme: %w", err)
+	}
+
+	var scheme struct {
+		Name      string            `json:"name"`
+		Variables map[string]string `json:"variables"`
+		Globals   map[string]string `json:"globals"`
+		Rules     []struct {
+			Scope      string  `json:"scope"`
+			Foreground string  `json:"foreground"`
+			Background string  `json:"background"`
+			FontStyle  *string `json:"font_style"`
+		} `json:"rules"`
+	}
+	if err := json.Unmarshal(stripJSONComments(data), &scheme); err != nil {
+		return nil, fmt.Errorf("failed to parse Sublime color scheme: %w", err)
+	}
+
+	resolve := func(value string) string {
+		for i := 0; i < 8 && strings.HasPrefix(value, "var("); i++ {
+			value = scheme.Variables[strings.TrimSuffix(strings.TrimPrefix(value, "var("), ")")]
+		}
+		return value
+	}
+
+	t := importedTheme{
+		name:          scheme.Name,
+		background:    resolve(scheme.Globals["background"]),
+		foreground:    resolve(scheme.Globals["foreground"]),
+		lineNumbers:   resolve(scheme.Globals["gutter_foreground"]),
+		lineHighlight: resolve(scheme.Globals["line_highlight"]),
+	}
+	for _, rule := range scheme.Rules {
+		r := scopeRule{
+			selectors:  splitSelectors([]string{rule.Scope}),
+			foreground: resolve(rule.Foreground),
+			background: resolve(rule.Background),
+		}
+		if rule.FontStyle != nil {
+			r.fontStyle = *rule.FontStyle
+			r.hasStyle = true
+		}
+		t.rules = append(t.rules, r)
+	}
+	return t.build()
+}
+
+// importedTheme is the format independent representation of an imported theme
+type importedTheme struct {
+	name          string
+	background    string
+	foreground    string
+	lineNumbers   string
+	lineHighlight string
+	rules         []scopeRule
+}
+
+// scopeRule styles the tokens matched by a list of TextMate scope selectors
+type scopeRule struct {
+	selectors  []string
+	foreground string
+	background string
+	fontStyle  string
+	hasStyle   bool
+}
+
+// scopeTokenTypes maps TextMate scopes to chroma token types. A scope rule
+// applies to a token type when the rule's selector is a prefix of the scope.
+var scopeTokenTypes = []struct {
+	scope     string
+	tokenType chroma.TokenType
+}{
+	{"comment", chroma.Comment},
+	{"comment.line", chroma.CommentSingle},
+	{"comment.block", chroma.CommentMultiline},
+	{"comment.block.preprocessor", chroma.CommentPreproc},
+	{"comment.block.documentation", chroma.CommentSpecial},
+	{"string", chroma.LiteralString},
+	{"string.quoted.double", chroma.LiteralStringDouble},
+	{"string.quoted.single", chroma.LiteralStringSingle},
+	{"string.quoted.other", chroma.LiteralStringOther},
+	{"string.quoted.docstring", chroma.LiteralStringDoc},
+	{"string.regexp", chroma.LiteralStringRegex},
+	{"string.template", chroma.LiteralStringBacktick},
+	{"string.interpolated", chroma.LiteralStringInterpol},
+	{"string.other.symbol", chroma.LiteralStringSymbol},
+	{"constant.character", chroma.LiteralStringChar},
+	{"constant.character.escape", chroma.LiteralStringEscape},
+	{"constant.numeric", chroma.LiteralNumber},
+	{"constant.numeric.integer", chroma.LiteralNumberInteger},
+	{"constant.numeric.float", chroma.LiteralNumberFloat},
+	{"constant.numeric.hex", chroma.LiteralNumberHex},
+	{"constant.numeric.octal", chroma.LiteralNumberOct},
+	{"constant.numeric.binary", chroma.LiteralNumberBin},
+	{"constant.language", chroma.KeywordConstant},
+	{"constant.other", chroma.NameConstant},
+	{"keyword", chroma.Keyword},
+	{"keyword.control.import", chroma.KeywordNamespace},
+	{"keyword.operator", chroma.Operator},
+	{"keyword.operator.word", chroma.OperatorWord},
+	{"keyword.other", chroma.KeywordPseudo},
+	{"storage", chroma.KeywordDeclaration},
+	{"storage.type", chroma.KeywordType},
+	{"storage.modifier", chroma.KeywordReserved},
+	{"entity.name", chroma.Name},
+	{"entity.name.function", chroma.NameFunction},
+	{"entity.name.function.decorator", chroma.NameDecorator},
+	{"entity.name.type", chroma.NameClass},
+	{"entity.name.class", chroma.NameClass},
+	{"entity.name.namespace", chroma.NameNamespace},
+	{"entity.name.tag", chroma.NameTag},
+	{"entity.name.label", chroma.NameLabel},
+	{"entity.name.exception", chroma.NameException},
+	{"entity.other.attribute-name", chroma.NameAttribute},
+	{"entity.other.inherited-class", chroma.NameClass},
+	{"meta.decorator", chroma.NameDecorator},
+	{"support.function", chroma.NameBuiltin},
+	{"support.type", chroma.KeywordType},
+	{"support.class", chroma.NameClass},
+	{"support.constant", chroma.NameConstant},
+	{"variable", chroma.NameVariable},
+	{"variable.language", chroma.NameBuiltinPseudo},
+	{"variable.other.constant", chroma.NameConstant},
+	{"variable.other.property", chroma.NameProperty},
+	{"variable.other.global", chroma.NameVariableGlobal},
+	{"punctuation", chroma.Punctuation},
+	{"markup.heading", chroma.GenericHeading},
+	{"markup.bold", chroma.GenericStrong},
+	{"markup.italic", chroma.GenericEmph},
+	{"markup.inserted", chroma.GenericInserted},
+	{"markup.deleted", chroma.GenericDeleted},
+	{"markup.raw", chroma.LiteralStringBacktick},
+	{"invalid", chroma.Error},
+}
+
+// build converts the imported theme into a chroma style
+func (t importedTheme) build() (*chroma.Style, error) {
+	name := t.name
+	if name == "" {
+		name = defaultThemeName
+	}
+	builder := chroma.NewStyleBuilder(name)
+
+	background := normalizeThemeColor(t.background, "")
+	foreground := normalizeThemeColor(t.foreground, background)
+	builder.Add(chroma.Background, strings.TrimSpace(foreground+" bg:"+background))
+	if foreground != "" {
+		builder.Add(chroma.Text, foreground)
+	}
+	if lineNumbers := normalizeThemeColor(t.lineNumbers, background); lineNumbers != "" {
+		builder.Add(chroma.LineNumbers, lineNumbers)
+	}
+	if lineHighlight := normalizeThemeColor(t.lineHighlight, background); lineHighlight != "" {
+		builder.Add(chroma.LineHighlight, "bg:"+lineHighlight)
+	}
+
+	for _, mapping := range scopeTokenTypes {
+		if entry := t.resolve(mapping.scope, background); entry != "" {
+			builder.Add(mapping.tokenType, entry)
+		}
+	}
+
+	style, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build theme: %w", err)
+	}
+	return style, nil
+}
+
+// resolve returns the chroma style entry for a scope. Every property is
+// taken from the most specific matching rule; later rules win ties.
+func (t importedTheme) resolve(scope, background string) string {
+	var foreground, tokenBackground, fontStyle string
+	fgScore, bgScore, styleScore := -1, -1, -1
+	for _, rule := range t.rules {
+		score := -1
+		for _, selector := range rule.selectors {
+			if scopeMatches(selector, scope) && len(selector) > score {
+				score = len(selector)
+			}
+		}
+		if score < 0 {
+			continue
+		}
+		if rule.foreground != "" && score >= fgScore {
+			foreground, fgScore = rule.foreground, score
+		}
+		if rule.background != "" && score >= bgScore {
+			tokenBackground, bgScore = rule.background, score
+		}
+		if rule.hasStyle && score >= styleScore {
+			fontStyle, styleScore = rule.fontStyle, score
+		}
+	}
+
+	var parts []string
+	if color := normalizeThemeColor(foreground, background); color != "" {
+		parts = append(parts, color)
+	}
+	if color := normalizeThemeColor(tokenBackground, background); color != "" {
+		parts = append(parts, "bg:"+color)
+	}
+	if styleScore >= 0 {
+		styles := strings.Fields(fontStyle)
+		for _, attr := range []string{"bold", "italic", "underline"} {
+			if containsString(styles, attr) {
+				parts = append(parts, attr)
+			} else {
+				parts = append(parts, "no"+attr)
+			}
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// splitSelectors splits comma separated scope selectors and keeps the last
+// element of descendant selectors such as "source.go comment"
+func splitSelectors(scopes []string) []string {
+	var selectors []string
+	for _, scope := range scopes {
+		for _, selector := range strings.Split(scope, ",") {
+			// Exclusions ("a - b") are not supported, only the positive part is used
+			selector, _, _ = strings.Cut(selector, " - ")
+			fields := strings.Fields(selector)
+			if len(fields) == 0 {
+				continue
+			}
+			selectors = append(selectors, fields[len(fields)-1])
+		}
+	}
+	return selectors
+}
+
+// scopeMatches checks if selector matches scope on a dot boundary
+func scopeMatches(selector, scope string) bool {
+	return selector == scope || strings.HasPrefix(scope, selector+".")
+}
+
+// normalizeThemeColor converts a theme color to #rrggbb, blending colors with
+// an alpha channel onto the background. Unsupported colors yield "".
+func normalizeThemeColor(color, background string) string {
+	color = strings.TrimPrefix(strings.TrimSpace(color), "#")
+	switch len(color) {
+	case 3, 4:
+		expanded := make([]byte, 0, 8)
+		for i := 0; i < len(color); i++ {
+			expanded = append(expanded, color[i], color[i])
+		}
+		color = string(expanded)
+	}
+	if len(color) != 6 && len(color) != 8 {
+		return ""
+	}
+	value, err := strconv.ParseUint(color, 16, 32)
+	if err != nil {
+		return ""
+	}
+	if len(color) == 6 {
+		return "#" + strings.ToLower(color)
+	}
+
+	alpha := float64(value&0xff) / 255
+	rgb := value >> 8
+	bg := chroma.ParseColour(background)
+	blend := func(shift uint, base uint8) uint64 {
+		c := float64((rgb >> shift) & 0xff)
+		return uint64(c*alpha + float64(base)*(1-alpha) + 0.5)
+	}
+	return fmt.Sprintf("#%02x%02x%02x", blend(16, bg.Red()), blend(8, bg.Green()), blend(0, bg.Blue()))
+}
+
+// stripJSONComments removes // and /* */ comments and trailing commas so that
+// JSON with comments (as used by VS Code and Sublime Text) can be decoded
+func stripJSONComments(data []byte) []byte {
+	var out bytes.Buffer
+	inString := false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			out.WriteByte(c)
+			if c == '\\' && i+1 < len(data) {
+				i++
+				out.WriteByte(data[i])
+			} else if c == '"' {
+				inString = false
+			}
+			continue
+		}
+		switch {
+		case c == '"':
+			inString = true
+			out.WriteByte(c)
+		case c == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			out.WriteByte('\n')
+		case c == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				i = len(data)
+			} else {
+				i += end + 3
+			}
+		case c == ']' || c == '}':
+			// Drop a trailing comma before the closing bracket
+			trimmed := bytes.TrimRight(out.Bytes(), " \t\r\n")
+			if len(trimmed) > 0 && trimmed[len(trimmed)-1] == ',' {
+				out.Truncate(len(trimmed) - 1)
+			}
+			out.WriteByte(c)
+		default:
+			out.WriteByte(c)
+		}
+	}
+	return out.Bytes()
+}
+
+// decodePlist decodes the value of an XML property list
+func decodePlist(d *xml.Decoder) (any, error) {
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return nil, err
+		}
+		if start, ok := tok.(xml.StartElement); ok {
+			if start.Name.Local == "plist" {
+				continue
+			}
+			return decodePlistValue(d, start)
+		}
+	}
+}
+
+// decodePlistValue decodes a single plist value starting at the given element
+func decodePlistValue(d *xml.Decoder, start xml.StartElement) (any, error) {
+	switch start.Name.Local {
+	case "dict":
+		dict := map[string]any{}
+		var key string
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				if t.Name.Local == "key" {
+					if err := d.DecodeElement(&key, &t); err != nil {
+						return nil, err
+					}
+					continue
+				}
+				value, err := decodePlistValue(d, t)
+				if err != nil {
+					return nil, err
+				}
+				dict[key] = value
+			case xml.EndElement:
+				return dict, nil
+			}
+		}
+	case "array":
+		var array []any
+		for {
+			tok, err := d.Token()
+			if err != nil {
+				return nil, err
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				value, err := decodePlistValue(d, t)
+				if err != nil {
+					return nil, err
+				}
+				array = append(array, value)
+			case xml.EndElement:
+				return array, nil
+			}
+		}
+	case "true", "false":
+		if err := d.Skip(); err != nil {
+			return nil, err
+		}
+		return start.Name.Local == "true", nil
+	default:
+		var text string
+		if err := d.DecodeElement(&text, &start); err != nil {
+			return nil, err
+		}
+		return strings.TrimSpace(text), nil
+	}
+}
+
+// firstNonEmpty returns the first non-empty string
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// containsString checks if values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,161 @@
+package freezelib
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+const vscodeTheme = `{
+	// VS Code themes may contain comments
+	"name": "Test Dark",
+	"type": "dark",
+	"colors": {
+		"editor.background": "#1e1e1e",
+		"editor.foreground": "#d4d4d4",
+		"editorLineNumber.foreground": "#858585",
+	},
+	"tokenColors": [
+		{"scope": "comment", "settings": {"foreground": "#6a9955", "fontStyle": "italic"}},
+		{"scope": ["keyword", "storage.type"], "settings": {"foreground": "#569cd6", "fontStyle": "bold"}},
+		{"scope": "keyword.operator", "settings": {"foreground": "#ffffff80"}},
+		{"scope": "source.go string, string.quoted", "settings": {"foreground": "#ce9178"}},
+	]
+}`
+
+const textMateTheme = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
+<plist version="1.0">
+<dict>
+	<key>name</key>
+	<string>Test Light</string>
+	<key>settings</key>
+	<array>
+		<dict>
+			<key>settings</key>
+			<dict>
+				<key>background</key>
+				<string>#FAFAFA</string>
+				<key>foreground</key>
+				<string>#383A42</string>
+				<key>gutterForeground</key>
+				<string>#9D9D9F</string>
+			</dict>
+		</dict>
+		<dict>
+			<key>scope</key>
+			<string>comment</string>
+			<key>settings</key>
+			<dict>
+				<key>foreground</key>
+				<string>#A0A1A7</string>
+				<key>fontStyle</key>
+				<string>italic</string>
+			</dict>
+		</dict>
+		<dict>
+			<key>scope</key>
+			<string>entity.name.function, support.function</string>
+			<key>settings</key>
+			<dict>
+				<key>foreground</key>
+				<string>#4078F2</string>
+			</dict>
+		</dict>
+	</array>
+</dict>
+</plist>`
+
+const sublimeScheme = `{
+	"name": "Test Sublime",
+	"variables": {"green": "#98c379", "accent": "var(green)"},
+	"globals": {"background": "#282c34", "foreground": "#abb2bf"},
+	"rules": [
+		{"scope": "string", "foreground": "var(accent)"},
+		{"scope": "keyword", "foreground": "#c678dd", "font_style": "bold italic"}
+	]
+}`
+
+func TestLoadVSCodeTheme(t *testing.T) {
+	style, err := LoadVSCodeTheme(strings.NewReader(vscodeTheme))
+	if err != nil {
+		t.Fatalf("LoadVSCodeTheme failed: %v", err)
+	}
+
+	checkThemeEntries(t, style, map[chroma.TokenType]string{
+		chroma.Background:          "#d4d4d4 bg:#1e1e1e",
+		chroma.LineNumbers:         "#858585 bg:#1e1e1e",
+		chroma.CommentSingle:       "italic nounderline #6a9955",
+		chroma.Comment:             "nobold italic nounderline #6a9955 bg:#1e1e1e",
+		chroma.KeywordType:         "bold noitalic nounderline #569cd6 bg:#1e1e1e",
+		chroma.Operator:            "#8f8f8f bg:#1e1e1e",
+		chroma.LiteralStringDouble: "#ce9178 bg:#1e1e1e",
+	})
+}
+
+func TestLoadTextMateTheme(t *testing.T) {
+	style, err := LoadTextMateTheme(strings.NewReader(textMateTheme))
+	if err != nil {
+		t.Fatalf("LoadTextMateTheme failed: %v", err)
+	}
+	if style.Name != "Test Light" {
+		t.Errorf("Name = %q, want %q", style.Name, "Test Light")
+	}
+
+	checkThemeEntries(t, style, map[chroma.TokenType]string{
+		chroma.Background:   "#383a42 bg:#fafafa",
+		chroma.Comment:      "nobold italic nounderline #a0a1a7 bg:#fafafa",
+		chroma.NameFunction: "#4078f2 bg:#fafafa",
+		chroma.NameBuiltin:  "#4078f2 bg:#fafafa",
+	})
+}
+
+func TestLoadSublimeColorScheme(t *testing.T) {
+	style, err := LoadSublimeColorScheme(strings.NewReader(sublimeScheme))
+	if err != nil {
+		t.Fatalf("LoadSublimeColorScheme failed: %v", err)
+	}
+
+	checkThemeEntries(t, style, map[chroma.TokenType]string{
+		chroma.LiteralString: "#98c379 bg:#282c34",
+		chroma.Keyword:       "bold italic nounderline #c678dd bg:#282c34",
+	})
+}
+
+func TestRegisterTheme(t *testing.T) {
+	style, err := LoadVSCodeTheme(strings.NewReader(vscodeTheme))
+	if err != nil {
+		t.Fatalf("LoadVSCodeTheme failed: %v", err)
+	}
+	if err := RegisterTheme(style); err != nil {
+		t.Fatalf("RegisterTheme failed: %v", err)
+	}
+	defer UnregisterTheme(style.Name)
+
+	if _, ok := styles.Registry["test dark"]; ok {
+		t.Error("RegisterTheme should not modify the global chroma registry")
+	}
+	if !New().IsThemeSupported("Test Dark") {
+		t.Error("Registered theme should be supported")
+	}
+
+	config := DefaultConfig().SetTheme("test dark")
+	resolved, err := config.resolveStyle()
+	if err != nil {
+		t.Fatalf("resolveStyle() failed: %v", err)
+	}
+	if resolved != style {
+		t.Error("Config.Theme should resolve to the registered theme")
+	}
+}
+
+func checkThemeEntries(t *testing.T, style *chroma.Style, expected map[chroma.TokenType]string) {
+	t.Helper()
+	for tokenType, entry := range expected {
+		if got := style.Get(tokenType).String(); !strings.Contains(got, entry) {
+			t.Errorf("%s = %q, want %q", tokenType, got, entry)
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package freezelib
+
+import (
+	"testing"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+func TestThemeOverrides(t *testing.T) {
+	bold := true
+	config := DefaultConfig()
+	config.SetTheme("monokai")
+	config.SetThemeOverride("Comment", TokenStyle{Color: "#ff0000", Bold: &bold})
+	config.SetThemeOverride("line_numbers", TokenStyle{Color: "#00ff00"})
+
+	original := styles.Get("monokai").Get(chroma.CommentSingle)
+
+	style, err := config.resolveStyle()
+	if err != nil {
+		t.Fatalf("resolveStyle() failed: %v", err)
+	}
+
+	for _, tokenType := range []chroma.TokenType{chroma.Comment, chroma.CommentSingle, chroma.CommentMultiline} {
+		entry := style.Get(tokenType)
+		if entry.Colour.String() != "#ff0000" {
+			t.Errorf("%s color = %s, want #ff0000", tokenType, entry.Colour)
+		}
+		if entry.Bold != chroma.Yes {
+			t.Errorf("%s should be bold", tokenType)
+		}
+	}
+	if color := style.Get(chroma.LineNumbers).Colour.String(); color != "#00ff00" {
+		t.Errorf("LineNumbers color = %s, want #00ff00", color)
+	}
+
+	// The global registry must not be modified
+	if styles.Get("monokai").Get(chroma.CommentSingle) != original {
+		t.Error("Theme overrides should not mutate the global style registry")
+	}
+}
+
+func TestThemeOverridesValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		tokenType string
+		style     TokenStyle
+	}{
+		{"Unknown token type", "NotAToken", TokenStyle{Color: "#ffffff"}},
+		{"Invalid color", "Keyword", TokenStyle{Color: "not-a-color"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.SetThemeOverride(tt.tokenType, tt.style)
+			if err := config.Validate(); err == nil {
+				t.Error("Validate() should fail")
+			}
+		})
+	}
+}